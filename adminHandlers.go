@@ -1,18 +1,111 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/JettMingin/chirpy-bootdev/internal/logging"
+	"github.com/google/uuid"
+)
+
+const (
+	roleUser      = "user"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
 )
 
+var roles = []string{roleUser, roleModerator, roleAdmin}
+
+// adminUserKey is where middlewareAdminAuth leaves the caller's user row
+type adminUserKey struct{}
+
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		cfg.fileServerHits.Add(1)
+		cfg.Metrics.fileServerHits.Inc()
 		next.ServeHTTP(w, req)
 	})
 }
 
+// guards every /admin route. /admin/moderation is open to moderators, the rest needs an admin
+func (cfg *apiConfig) middlewareAdminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		userID, err := cfg.getAuthedUserID(req)
+		if err != nil {
+			res.Header().Set("Content-Type", "application/json")
+			ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+			return
+		}
+		dbUser, err := cfg.DB.GetUser(req.Context(), userID)
+		if err != nil {
+			res.Header().Set("Content-Type", "application/json")
+			ErrorResponseWriter(res, "failed to find user from token in DB", err, 401)
+			return
+		}
+		if dbUser.SuspendedAt.Valid {
+			res.Header().Set("Content-Type", "application/json")
+			err := errors.New("account suspended")
+			ErrorResponseWriter(res, "this account has been suspended", err, 403)
+			return
+		}
+
+		//every admin write is audited, including the ones refused below
+		authedReq := req.WithContext(context.WithValue(req.Context(), adminUserKey{}, dbUser))
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			auditWriter := &statusWriter{ResponseWriter: res, status: 200}
+			defer func() { cfg.recordAdminRequest(authedReq, dbUser, auditWriter.status) }()
+			res = auditWriter
+		}
+
+		allowed := dbUser.Role == roleAdmin
+		if strings.HasPrefix(req.URL.Path, "/admin/moderation/") {
+			allowed = allowed || dbUser.Role == roleModerator
+		}
+		if !allowed {
+			res.Header().Set("Content-Type", "application/json")
+			err := errors.New("user role not allowed")
+			ErrorResponseWriter(res, "Admin Access Required", err, 403)
+			return
+		}
+		next.ServeHTTP(res, authedReq)
+		//hand the admin mux's pattern back out so the request metrics get the full route
+		req.Pattern = authedReq.Pattern
+	})
+}
+
+// suspended users can still read, anything else sent with their access token is refused.
+// requests without a valid access token go through untouched for the handler to deal with
+func (cfg *apiConfig) middlewareRejectSuspended(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodOptions {
+			next.ServeHTTP(res, req)
+			return
+		}
+		userID, err := cfg.getAuthedUserID(req)
+		if err != nil {
+			next.ServeHTTP(res, req)
+			return
+		}
+		dbUser, err := cfg.DB.GetUser(req.Context(), userID)
+		if err == nil && dbUser.SuspendedAt.Valid {
+			res.Header().Set("Content-Type", "application/json")
+			err := errors.New("account suspended")
+			ErrorResponseWriter(res, "this account has been suspended", err, 403)
+			return
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
+// human view over the same counters /metrics serves
 func (cfg *apiConfig) metricsHandler(res http.ResponseWriter, req *http.Request) {
 	res.Header().Set("Content-Type", "text/html")
 	resHTML := `
@@ -20,27 +113,319 @@ func (cfg *apiConfig) metricsHandler(res http.ResponseWriter, req *http.Request)
 			<body>
 				<h1>Welcome, Chirpy Admin</h1>
 				<p>Chirpy has been visited %d times!</p>
+				<ul>
+					<li>Requests served: %d</li>
+					<li>Chirps created: %d</li>
+					<li>Logins: %d succeeded, %d failed</li>
+					<li>Open streams: %d</li>
+				</ul>
 			</body>
 		</html>
 	`
-	hitVal := cfg.fileServerHits.Load()
+	hitVal := int64(cfg.Metrics.total("chirpy_fileserver_hits_total", "", "")) - cfg.fileServerHitsAtReset.Load()
 	res.WriteHeader(http.StatusOK)
-	res.Write(fmt.Appendf(nil, resHTML, hitVal))
+	res.Write(fmt.Appendf(nil, resHTML,
+		hitVal,
+		int64(cfg.Metrics.total("chirpy_http_requests_total", "", "")),
+		int64(cfg.Metrics.total("chirpy_chirps_created_total", "", "")),
+		int64(cfg.Metrics.total("chirpy_logins_total", "result", loginSuccess)),
+		int64(cfg.Metrics.total("chirpy_logins_total", "result", loginFailure)),
+		int64(cfg.Metrics.total("chirpy_stream_connections", "", "")),
+	))
 }
 
 func (cfg *apiConfig) postToReset(res http.ResponseWriter, req *http.Request) {
 	if cfg.Platform != "dev" {
-		log.Println("req from invalid platform (env PLATFORM discrepancy)")
+		logging.FromContext(req.Context()).Warn("reset refused, PLATFORM is not dev")
 		res.WriteHeader(403)
 		return
 	}
 
 	if err := cfg.DB.ResetUsers(req.Context()); err != nil {
-		log.Printf("Error clearing all rows in Users table: %s", err)
+		logging.FromContext(req.Context()).Error("clearing all rows in users table", "error", err)
 		res.WriteHeader(500)
 		return
 	}
 
-	cfg.fileServerHits.Store(0)
+	//prometheus counters only go up, so the page counts from here instead
+	cfg.fileServerHitsAtReset.Store(int64(cfg.Metrics.total("chirpy_fileserver_hits_total", "", "")))
 	res.WriteHeader(http.StatusOK)
 }
+
+// how the first admin gets in, and gets back in after a dev reset: an account whose email is in
+// ADMIN_EMAILS is promoted when it logs in. taking an email off the list doesn't demote anyone
+func (cfg *apiConfig) bootstrapAdmin(ctx context.Context, dbUser database.User) (database.User, error) {
+	if !cfg.AdminEmails[strings.ToLower(dbUser.Email)] || dbUser.Role == roleAdmin {
+		return dbUser, nil
+	}
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return dbUser, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	promotedUser, err := qtx.SetUserRole(ctx, database.SetUserRoleParams{Role: roleAdmin, ID: dbUser.ID})
+	if err != nil {
+		return dbUser, err
+	}
+	if err := qtx.CreateModerationAction(ctx, database.CreateModerationActionParams{
+		Action:       moderationSetRole,
+		TargetUserID: uuid.NullUUID{UUID: dbUser.ID, Valid: true},
+		Notes:        "role set to admin from ADMIN_EMAILS",
+	}); err != nil {
+		return dbUser, err
+	}
+	if err := tx.Commit(); err != nil {
+		return dbUser, err
+	}
+	logging.FromContext(ctx).Info("promoted admin from ADMIN_EMAILS", "user_id", dbUser.ID)
+	return promotedUser, nil
+}
+
+// comma separated, compared without case
+func parseAdminEmails(raw string) map[string]bool {
+	adminEmails := map[string]bool{}
+	for _, email := range strings.Split(raw, ",") {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			adminEmails[email] = true
+		}
+	}
+	return adminEmails
+}
+
+// the user row middlewareAdminAuth loaded for this request
+func adminUserFromContext(ctx context.Context) (database.User, bool) {
+	dbUser, ok := ctx.Value(adminUserKey{}).(database.User)
+	return dbUser, ok
+}
+
+type AdminUser struct {
+	ID          uuid.UUID  `json:"id"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	IsChirpyRed bool       `json:"is_chirpy_red"`
+	SuspendedAt *time.Time `json:"suspended_at"`
+}
+
+func adminUserFromDB(dbUser database.User) AdminUser {
+	return AdminUser{
+		ID:          dbUser.ID,
+		Email:       dbUser.Email,
+		Role:        dbUser.Role,
+		IsChirpyRed: dbUser.IsChirpyRed,
+		SuspendedAt: nullTimePtr(dbUser.SuspendedAt),
+	}
+}
+
+// reads {userId} and an optional {notes} body, writing the error response itself when either is unusable.
+// admins can't act on their own account so nobody locks themselves out
+func readAdminUserRequest(res http.ResponseWriter, req *http.Request) (database.User, uuid.UUID, string, bool) {
+	adminUser, ok := adminUserFromContext(req.Context())
+	if !ok {
+		err := errors.New("no admin user on request")
+		ErrorResponseWriter(res, "Admin Access Required", err, 403)
+		return database.User{}, uuid.UUID{}, "", false
+	}
+	targetID, err := uuid.Parse(req.PathValue("userId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return database.User{}, uuid.UUID{}, "", false
+	}
+	if targetID == adminUser.ID {
+		err := errors.New("target is the requesting user")
+		ErrorResponseWriter(res, "admins can't change their own account here", err, 400)
+		return database.User{}, uuid.UUID{}, "", false
+	}
+
+	reqData, err := io.ReadAll(req.Body)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to read request body", err, 500)
+		return database.User{}, uuid.UUID{}, "", false
+	}
+	adminReq := map[string]string{}
+	if len(reqData) > 0 {
+		if err := json.Unmarshal(reqData, &adminReq); err != nil {
+			ErrorResponseWriter(res, "Failed to decode request body", err, 400)
+			return database.User{}, uuid.UUID{}, "", false
+		}
+	}
+	if len(adminReq["notes"]) > maxModerationNotesLength {
+		err := errors.New("notes too long")
+		ErrorResponseWriter(res, "notes must be 1000 characters or fewer", err, 400)
+		return database.User{}, uuid.UUID{}, "", false
+	}
+	return adminUser, targetID, adminReq["notes"], true
+}
+
+// suspension also revokes every refresh token, the access token left is useless for writes
+func (cfg *apiConfig) SuspendUser(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	adminUser, targetID, notes, ok := readAdminUserRequest(res, req)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to start DB transaction", err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	if _, err := qtx.GetUser(req.Context(), targetID); err != nil {
+		ErrorResponseWriter(res, "failed to find user with provided id in DB", err, 404)
+		return
+	}
+	suspended, err := qtx.SuspendUser(req.Context(), targetID)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to suspend user in DB", err, 500)
+		return
+	}
+	if suspended == 0 {
+		err := errors.New("user already suspended")
+		ErrorResponseWriter(res, "user is already suspended", err, 409)
+		return
+	}
+	if err := qtx.RevokeUsersRefreshTokens(req.Context(), targetID); err != nil {
+		ErrorResponseWriter(res, "Failed to revoke refresh tokens in DB", err, 500)
+		return
+	}
+	if err := qtx.CreateModerationAction(req.Context(), database.CreateModerationActionParams{
+		ModeratorID:  uuid.NullUUID{UUID: adminUser.ID, Valid: true},
+		Action:       moderationSuspendUser,
+		TargetUserID: uuid.NullUUID{UUID: targetID, Valid: true},
+		Notes:        notes,
+	}); err != nil {
+		ErrorResponseWriter(res, "Failed to write moderation action to DB", err, 500)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		ErrorResponseWriter(res, "Failed to commit DB transaction", err, 500)
+		return
+	}
+	res.WriteHeader(204)
+}
+
+func (cfg *apiConfig) UnsuspendUser(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	adminUser, targetID, notes, ok := readAdminUserRequest(res, req)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to start DB transaction", err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	if _, err := qtx.GetUser(req.Context(), targetID); err != nil {
+		ErrorResponseWriter(res, "failed to find user with provided id in DB", err, 404)
+		return
+	}
+	unsuspended, err := qtx.UnsuspendUser(req.Context(), targetID)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to unsuspend user in DB", err, 500)
+		return
+	}
+	if unsuspended == 0 {
+		err := errors.New("user not suspended")
+		ErrorResponseWriter(res, "user is not suspended", err, 409)
+		return
+	}
+	if err := qtx.CreateModerationAction(req.Context(), database.CreateModerationActionParams{
+		ModeratorID:  uuid.NullUUID{UUID: adminUser.ID, Valid: true},
+		Action:       moderationUnsuspendUser,
+		TargetUserID: uuid.NullUUID{UUID: targetID, Valid: true},
+		Notes:        notes,
+	}); err != nil {
+		ErrorResponseWriter(res, "Failed to write moderation action to DB", err, 500)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		ErrorResponseWriter(res, "Failed to commit DB transaction", err, 500)
+		return
+	}
+	res.WriteHeader(204)
+}
+
+func (cfg *apiConfig) SetUserRole(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	adminUser, ok := adminUserFromContext(req.Context())
+	if !ok {
+		err := errors.New("no admin user on request")
+		ErrorResponseWriter(res, "Admin Access Required", err, 403)
+		return
+	}
+	targetID, err := uuid.Parse(req.PathValue("userId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+	if targetID == adminUser.ID {
+		err := errors.New("target is the requesting user")
+		ErrorResponseWriter(res, "admins can't change their own role", err, 400)
+		return
+	}
+	reqData, err := io.ReadAll(req.Body)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to read request body", err, 500)
+		return
+	}
+	var roleReq map[string]string
+	if err := json.Unmarshal(reqData, &roleReq); err != nil {
+		ErrorResponseWriter(res, "Failed to decode request body", err, 400)
+		return
+	}
+	if !slices.Contains(roles, roleReq["role"]) {
+		err := errors.New("unknown role")
+		ErrorResponseWriter(res, "role must be user, moderator or admin", err, 400)
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to start DB transaction", err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	dbUser, err := qtx.SetUserRole(req.Context(), database.SetUserRoleParams{Role: roleReq["role"], ID: targetID})
+	if errors.Is(err, sql.ErrNoRows) {
+		ErrorResponseWriter(res, "failed to find user with provided id in DB", err, 404)
+		return
+	}
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to update user role in DB", err, 500)
+		return
+	}
+	if err := qtx.CreateModerationAction(req.Context(), database.CreateModerationActionParams{
+		ModeratorID:  uuid.NullUUID{UUID: adminUser.ID, Valid: true},
+		Action:       moderationSetRole,
+		TargetUserID: uuid.NullUUID{UUID: targetID, Valid: true},
+		Notes:        "role set to " + dbUser.Role,
+	}); err != nil {
+		ErrorResponseWriter(res, "Failed to write moderation action to DB", err, 500)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		ErrorResponseWriter(res, "Failed to commit DB transaction", err, 500)
+		return
+	}
+
+	successRes, err := json.Marshal(adminUserFromDB(dbUser))
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/audit"
+	"github.com/JettMingin/chirpy-bootdev/internal/clientip"
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/JettMingin/chirpy-bootdev/internal/logging"
+	"github.com/google/uuid"
+)
+
+// audit_events.action values
+const (
+	auditLoginSucceeded      = "login.succeeded"
+	auditLoginFailed         = "login.failed"
+	auditRefreshTokenIssued  = "refresh_token.issued"
+	auditRefreshTokenRevoked = "refresh_token.revoked"
+	auditEmailChanged        = "user.email_changed"
+	auditPasswordChanged     = "user.password_changed"
+	auditSubscriptionChanged = "subscription.changed"
+	auditChirpDeleted        = "chirp.deleted"
+	auditAdminRequest        = "admin.request"
+)
+
+// which path wildcard names the target of an admin request, most specific first
+var auditAdminTargets = []struct {
+	wildcard   string
+	targetType string
+}{
+	{"userId", "user"},
+	{"reportId", "report"},
+	{"ruleId", "filter_rule"},
+	{"decisionId", "spam_decision"},
+	{"deliveryId", "webhook_delivery"},
+	{"webhookId", "webhook"},
+}
+
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Action     string          `json:"action"`
+	ActorID    *uuid.UUID      `json:"actor_id"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"user_agent"`
+	Details    json.RawMessage `json:"details"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+func auditEventFromDB(dbEvent database.AuditEvent) AuditEvent {
+	return AuditEvent{
+		ID:         dbEvent.ID,
+		CreatedAt:  dbEvent.CreatedAt,
+		Action:     dbEvent.Action,
+		ActorID:    nullUUIDPtr(dbEvent.ActorID),
+		TargetType: dbEvent.TargetType,
+		TargetID:   dbEvent.TargetID,
+		IP:         dbEvent.Ip,
+		UserAgent:  dbEvent.UserAgent,
+		Details:    dbEvent.Details,
+		PrevHash:   dbEvent.PrevHash,
+		Hash:       dbEvent.Hash,
+	}
+}
+
+// RemoteAddr, or X-Forwarded-For when the request came through one of TRUSTED_PROXIES
+func (cfg *apiConfig) clientIP(req *http.Request) string {
+	return clientip.Resolve(req, cfg.TrustedProxies)
+}
+
+// an event for this request with the caller's ip and user agent filled in
+func (cfg *apiConfig) newAuditEvent(req *http.Request, action string) audit.Event {
+	return audit.Event{
+		Action:    action,
+		IP:        cfg.clientIP(req),
+		UserAgent: req.UserAgent(),
+		Details:   map[string]string{},
+	}
+}
+
+// refresh tokens are bearer secrets, the log only keeps enough of their hash to tell them apart
+func refreshTokenFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// chains event onto the newest audit row. qtx has to be inside a transaction: the advisory lock
+// holds off every other writer until it commits, so no two events ever share a prev_hash
+func appendAuditEvent(ctx context.Context, qtx *database.Queries, event audit.Event) error {
+	if err := qtx.LockAuditChain(ctx); err != nil {
+		return err
+	}
+	prevHash, err := qtx.GetLatestAuditHash(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	if event.Details == nil {
+		event.Details = map[string]string{}
+	}
+	details, err := json.Marshal(event.Details)
+	if err != nil {
+		return err
+	}
+	_, err = qtx.CreateAuditEvent(ctx, database.CreateAuditEventParams{
+		CreatedAt:  event.CreatedAt,
+		Action:     event.Action,
+		ActorID:    event.ActorID,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		Ip:         event.IP,
+		UserAgent:  event.UserAgent,
+		Details:    details,
+		PrevHash:   prevHash,
+		Hash:       audit.Hash(prevHash, event),
+	})
+	return err
+}
+
+// for events that aren't part of a bigger transaction. failures are logged rather than
+// returned, by the time we get here the thing being recorded has already happened
+func (cfg *apiConfig) recordAuditEvent(ctx context.Context, event audit.Event) {
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		logging.FromContext(ctx).Error("recording audit event", "action", event.Action, "error", err)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := appendAuditEvent(ctx, cfg.withTx(tx), event); err != nil {
+		logging.FromContext(ctx).Error("recording audit event", "action", event.Action, "error", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		logging.FromContext(ctx).Error("recording audit event", "action", event.Action, "error", err)
+	}
+}
+
+// one admin.request event per admin write, after the handler has run. req is the request the
+// admin mux saw, so its pattern and path values are set
+func (cfg *apiConfig) recordAdminRequest(req *http.Request, adminUser database.User, status int) {
+	event := cfg.newAuditEvent(req, auditAdminRequest)
+	event.ActorID = uuid.NullUUID{UUID: adminUser.ID, Valid: true}
+	for _, target := range auditAdminTargets {
+		if targetID := req.PathValue(target.wildcard); targetID != "" {
+			event.TargetType = target.targetType
+			event.TargetID = targetID
+			break
+		}
+	}
+	event.Details["role"] = adminUser.Role
+	event.Details["route"] = req.Pattern
+	event.Details["path"] = req.URL.Path
+	event.Details["status"] = strconv.Itoa(status)
+	//the request context may be canceled once the client has its response, its logger is still wanted
+	cfg.recordAuditEvent(context.WithoutCancel(req.Context()), event)
+}
+
+func (cfg *apiConfig) GetAuditEvents(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	query := req.URL.Query()
+	params := database.GetAuditEventsParams{
+		Action:     sql.NullString{String: query.Get("action"), Valid: query.Get("action") != ""},
+		TargetType: sql.NullString{String: query.Get("target_type"), Valid: query.Get("target_type") != ""},
+		TargetID:   sql.NullString{String: query.Get("target_id"), Valid: query.Get("target_id") != ""},
+	}
+	if rawActorID := query.Get("actor_id"); rawActorID != "" {
+		actorID, err := uuid.Parse(rawActorID)
+		if err != nil {
+			ErrorResponseWriter(res, "actor_id must be a uuid", err, 400)
+			return
+		}
+		params.ActorID = uuid.NullUUID{UUID: actorID, Valid: true}
+	}
+	for _, bound := range []struct {
+		name string
+		dest *sql.NullTime
+	}{{"since", &params.Since}, {"until", &params.Until}} {
+		rawTime := query.Get(bound.name)
+		if rawTime == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, rawTime)
+		if err != nil {
+			ErrorResponseWriter(res, bound.name+" must be an RFC 3339 timestamp", err, 400)
+			return
+		}
+		*bound.dest = sql.NullTime{Time: parsed.UTC(), Valid: true}
+	}
+	limit, offset, err := getPaginationParams(req)
+	if err != nil {
+		ErrorResponseWriter(res, "invalid pagination params", err, 400)
+		return
+	}
+	params.RowLimit = limit
+	params.RowOffset = offset
+
+	dbEvents, err := cfg.DB.GetAuditEvents(req.Context(), params)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for audit events in DB", err, 500)
+		return
+	}
+
+	selectedEvents := []AuditEvent{}
+	for _, row := range dbEvents {
+		selectedEvents = append(selectedEvents, auditEventFromDB(row))
+	}
+
+	successRes, err := json.Marshal(selectedEvents)
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
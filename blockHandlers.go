@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+)
+
+// blocking hides the other user's chirps from you and stops them messaging you or showing up in your
+// notifications. muting only hides their chirps
+
+// reads {userId} off the url and writes the error response itself when it's unusable
+func (cfg *apiConfig) getTargetUserID(res http.ResponseWriter, req *http.Request, userID uuid.UUID) (uuid.UUID, bool) {
+	targetID, err := uuid.Parse(req.PathValue("userId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return uuid.UUID{}, false
+	}
+	if targetID == userID {
+		err := errors.New("target is the requesting user")
+		ErrorResponseWriter(res, "you can't block, mute or follow yourself", err, 400)
+		return uuid.UUID{}, false
+	}
+	if _, err := cfg.DB.GetUser(req.Context(), targetID); err != nil {
+		ErrorResponseWriter(res, "failed to find user with provided id in DB", err, 404)
+		return uuid.UUID{}, false
+	}
+	return targetID, true
+}
+
+func (cfg *apiConfig) BlockUser(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	targetID, ok := cfg.getTargetUserID(res, req, userID)
+	if !ok {
+		return
+	}
+
+	if err := cfg.DB.CreateUserBlock(req.Context(),
+		database.CreateUserBlockParams{BlockerID: userID, BlockedID: targetID}); err != nil {
+		ErrorResponseWriter(res, "Failed to write block to DB", err, 500)
+		return
+	}
+	//a blocked user stops following you too, and can't follow again while the block stands
+	if _, err := cfg.DB.DeleteUserFollow(req.Context(),
+		database.DeleteUserFollowParams{FollowerID: targetID, FollowedID: userID}); err != nil {
+		ErrorResponseWriter(res, "Failed to delete follow in DB", err, 500)
+		return
+	}
+	res.WriteHeader(204)
+}
+
+func (cfg *apiConfig) UnblockUser(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	targetID, err := uuid.Parse(req.PathValue("userId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+
+	deleted, err := cfg.DB.DeleteUserBlock(req.Context(),
+		database.DeleteUserBlockParams{BlockerID: userID, BlockedID: targetID})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to delete block in DB", err, 500)
+		return
+	}
+	if deleted == 0 {
+		err := errors.New("block not found")
+		ErrorResponseWriter(res, "you have not blocked this user", err, 404)
+		return
+	}
+	res.WriteHeader(204)
+}
+
+func (cfg *apiConfig) MuteUser(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	targetID, ok := cfg.getTargetUserID(res, req, userID)
+	if !ok {
+		return
+	}
+
+	if err := cfg.DB.CreateUserMute(req.Context(),
+		database.CreateUserMuteParams{MuterID: userID, MutedID: targetID}); err != nil {
+		ErrorResponseWriter(res, "Failed to write mute to DB", err, 500)
+		return
+	}
+	res.WriteHeader(204)
+}
+
+func (cfg *apiConfig) UnmuteUser(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	targetID, err := uuid.Parse(req.PathValue("userId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+
+	deleted, err := cfg.DB.DeleteUserMute(req.Context(),
+		database.DeleteUserMuteParams{MuterID: userID, MutedID: targetID})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to delete mute in DB", err, 500)
+		return
+	}
+	if deleted == 0 {
+		err := errors.New("mute not found")
+		ErrorResponseWriter(res, "you have not muted this user", err, 404)
+		return
+	}
+	res.WriteHeader(204)
+}
+
+// authors whose chirps a viewer shouldn't see on live streams. loaded once when the stream opens
+func (cfg *apiConfig) getHiddenAuthors(req *http.Request, viewerID uuid.NullUUID) (map[uuid.UUID]bool, error) {
+	hiddenAuthors := map[uuid.UUID]bool{}
+	if !viewerID.Valid {
+		return hiddenAuthors, nil
+	}
+	authorIDs, err := cfg.DB.GetHiddenAuthorIDs(req.Context(), viewerID.UUID)
+	if err != nil {
+		return nil, err
+	}
+	for _, authorID := range authorIDs {
+		hiddenAuthors[authorID] = true
+	}
+	return hiddenAuthors, nil
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+)
+
+type Bookmark struct {
+	Chirp
+	FolderID     *uuid.UUID `json:"folder_id"`
+	BookmarkedAt time.Time  `json:"bookmarked_at"`
+}
+type BookmarkFolder struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name"`
+}
+
+func (cfg *apiConfig) PostBookmark(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	reqChirpId, err := uuid.Parse(req.PathValue("chirpId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+	if _, err := cfg.DB.GetOneChirp(req.Context(), reqChirpId); err != nil {
+		ErrorResponseWriter(res, "failed to find chirp with provided id in DB", err, 404)
+		return
+	}
+
+	//body is optional, it only matters when filing the bookmark into a folder
+	reqData, err := io.ReadAll(req.Body)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to read request body", err, 500)
+		return
+	}
+	bookmarkReq := map[string]string{}
+	if len(reqData) > 0 {
+		if err := json.Unmarshal(reqData, &bookmarkReq); err != nil {
+			ErrorResponseWriter(res, "Failed to decode request body", err, 400)
+			return
+		}
+	}
+
+	folderID, ok := cfg.parseBookmarkFolderID(res, req, userID, bookmarkReq["folder_id"])
+	if !ok {
+		return
+	}
+
+	dbBookmark, err := cfg.DB.CreateBookmark(req.Context(), database.CreateBookmarkParams{
+		UserID:   userID,
+		ChirpID:  reqChirpId,
+		FolderID: folderID,
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to write bookmark to DB", err, 500)
+		return
+	}
+
+	successRes, err := json.Marshal(map[string]any{
+		"chirp_id":      dbBookmark.ChirpID,
+		"folder_id":     nullUUIDPtr(dbBookmark.FolderID),
+		"bookmarked_at": dbBookmark.CreatedAt,
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(201)
+	res.Write(successRes)
+}
+
+// files an existing bookmark into another folder, or out of any folder when folder_id is empty.
+// POST only ever adds a folder, re-bookmarking without one keeps the bookmark where it was
+func (cfg *apiConfig) MoveBookmark(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	reqChirpId, err := uuid.Parse(req.PathValue("chirpId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+
+	reqData, err := io.ReadAll(req.Body)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to read request body", err, 500)
+		return
+	}
+	var moveReq map[string]*string
+	if err := json.Unmarshal(reqData, &moveReq); err != nil {
+		ErrorResponseWriter(res, "Failed to decode request body", err, 400)
+		return
+	}
+	rawFolderID := ""
+	if moveReq["folder_id"] != nil {
+		rawFolderID = *moveReq["folder_id"]
+	}
+	folderID, ok := cfg.parseBookmarkFolderID(res, req, userID, rawFolderID)
+	if !ok {
+		return
+	}
+
+	dbBookmark, err := cfg.DB.MoveBookmark(req.Context(), database.MoveBookmarkParams{
+		FolderID: folderID,
+		UserID:   userID,
+		ChirpID:  reqChirpId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		ErrorResponseWriter(res, "chirp is not bookmarked", err, 404)
+		return
+	}
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to move bookmark in DB", err, 500)
+		return
+	}
+
+	successRes, err := json.Marshal(map[string]any{
+		"chirp_id":      dbBookmark.ChirpID,
+		"folder_id":     nullUUIDPtr(dbBookmark.FolderID),
+		"bookmarked_at": dbBookmark.CreatedAt,
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+// an empty folder_id is no folder. anything else has to be one of the user's folders, and folders
+// are a Chirpy Red feature. writes the error response itself when it returns false
+func (cfg *apiConfig) parseBookmarkFolderID(res http.ResponseWriter, req *http.Request, userID uuid.UUID, rawFolderID string) (uuid.NullUUID, bool) {
+	if rawFolderID == "" {
+		return uuid.NullUUID{}, true
+	}
+	parsedFolderID, err := uuid.Parse(rawFolderID)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse folder_id", err, 400)
+		return uuid.NullUUID{}, false
+	}
+	entitlements, err := cfg.getEntitlements(req.Context(), userID)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to look up user entitlements", err, 500)
+		return uuid.NullUUID{}, false
+	}
+	if !entitlements.BookmarkFolders {
+		err := errors.New("bookmark folders require chirpy red")
+		ErrorResponseWriter(res, "Chirpy Red Required", err, 403)
+		return uuid.NullUUID{}, false
+	}
+	if _, err := cfg.DB.GetBookmarkFolder(req.Context(),
+		database.GetBookmarkFolderParams{ID: parsedFolderID, UserID: userID}); err != nil {
+		ErrorResponseWriter(res, "failed to find bookmark folder with provided id in DB", err, 404)
+		return uuid.NullUUID{}, false
+	}
+	return uuid.NullUUID{UUID: parsedFolderID, Valid: true}, true
+}
+
+func (cfg *apiConfig) DeleteBookmark(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	reqChirpId, err := uuid.Parse(req.PathValue("chirpId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+
+	deleted, err := cfg.DB.DeleteBookmark(req.Context(),
+		database.DeleteBookmarkParams{UserID: userID, ChirpID: reqChirpId})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to delete bookmark in DB", err, 500)
+		return
+	}
+	if deleted == 0 {
+		err := errors.New("bookmark not found")
+		ErrorResponseWriter(res, "chirp is not bookmarked", err, 404)
+		return
+	}
+	res.WriteHeader(204)
+}
+
+func (cfg *apiConfig) GetBookmarks(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	limit, offset, err := getPaginationParams(req)
+	if err != nil {
+		ErrorResponseWriter(res, "invalid pagination params", err, 400)
+		return
+	}
+
+	//both queries return the same columns, so squash them into one row type
+	var dbBookmarks []database.GetUserBookmarksRow
+	if rawFolderID := req.URL.Query().Get("folder_id"); rawFolderID == "" {
+		dbBookmarks, err = cfg.DB.GetUserBookmarks(req.Context(),
+			database.GetUserBookmarksParams{UserID: userID, Limit: limit, Offset: offset})
+	} else {
+		folderID, parseErr := uuid.Parse(rawFolderID)
+		if parseErr != nil {
+			ErrorResponseWriter(res, "failed to parse folder_id", parseErr, 400)
+			return
+		}
+		var folderRows []database.GetFolderBookmarksRow
+		folderRows, err = cfg.DB.GetFolderBookmarks(req.Context(), database.GetFolderBookmarksParams{
+			UserID:   userID,
+			FolderID: uuid.NullUUID{UUID: folderID, Valid: true},
+			Limit:    limit,
+			Offset:   offset,
+		})
+		for _, row := range folderRows {
+			dbBookmarks = append(dbBookmarks, database.GetUserBookmarksRow(row))
+		}
+	}
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for bookmarks in DB", err, 500)
+		return
+	}
+
+	selectedBookmarks := []Bookmark{}
+	for _, row := range dbBookmarks {
+		selectedBookmarks = append(selectedBookmarks, Bookmark{
+			Chirp: Chirp{
+				ID:        row.ID,
+				CreatedAt: row.CreatedAt,
+				UpdatedAt: row.UpdatedAt,
+				Body:      row.Body,
+				UserID:    row.UserID,
+				ReplyToID: nullUUIDPtr(row.ReplyToID),
+			},
+			FolderID:     nullUUIDPtr(row.FolderID),
+			BookmarkedAt: row.BookmarkedAt,
+		})
+	}
+
+	successRes, err := json.Marshal(selectedBookmarks)
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) PostBookmarkFolder(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	entitlements, err := cfg.getEntitlements(req.Context(), userID)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to look up user entitlements", err, 500)
+		return
+	}
+	if !entitlements.BookmarkFolders {
+		err := errors.New("bookmark folders require chirpy red")
+		ErrorResponseWriter(res, "Chirpy Red Required", err, 403)
+		return
+	}
+
+	reqData, err := io.ReadAll(req.Body)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to read request body", err, 500)
+		return
+	}
+	var folderReq map[string]string
+	if err := json.Unmarshal(reqData, &folderReq); err != nil {
+		ErrorResponseWriter(res, "Failed to decode request body", err, 400)
+		return
+	}
+	folderName := strings.TrimSpace(folderReq["name"])
+	if folderName == "" || len(folderName) > 64 {
+		err := errors.New("missing or invalid name field")
+		ErrorResponseWriter(res, "name missing from body or longer than 64 characters", err, 400)
+		return
+	}
+
+	dbFolder, err := cfg.DB.CreateBookmarkFolder(req.Context(),
+		database.CreateBookmarkFolderParams{UserID: userID, Name: folderName})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to write bookmark folder to DB (names must be unique)", err, 409)
+		return
+	}
+
+	successRes, err := json.Marshal(BookmarkFolder{
+		ID:        dbFolder.ID,
+		CreatedAt: dbFolder.CreatedAt,
+		UpdatedAt: dbFolder.UpdatedAt,
+		Name:      dbFolder.Name,
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(201)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) GetBookmarkFolders(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	dbFolders, err := cfg.DB.GetBookmarkFolders(req.Context(), userID)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for bookmark folders in DB", err, 500)
+		return
+	}
+
+	selectedFolders := []BookmarkFolder{}
+	for _, row := range dbFolders {
+		selectedFolders = append(selectedFolders, BookmarkFolder{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			Name:      row.Name,
+		})
+	}
+
+	successRes, err := json.Marshal(selectedFolders)
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) DeleteBookmarkFolder(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	folderID, err := uuid.Parse(req.PathValue("folderId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+
+	//bookmarks inside the folder are kept, the FK just sets their folder_id to NULL
+	deleted, err := cfg.DB.DeleteBookmarkFolder(req.Context(),
+		database.DeleteBookmarkFolderParams{ID: folderID, UserID: userID})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to delete bookmark folder in DB", err, 500)
+		return
+	}
+	if deleted == 0 {
+		err := errors.New("bookmark folder not found")
+		ErrorResponseWriter(res, "failed to find bookmark folder with provided id in DB", err, 404)
+		return
+	}
+	res.WriteHeader(204)
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
@@ -0,0 +1,80 @@
+// auditverify walks audit_events from the first row and checks every event's hash chain.
+// it exits 1 at the first event that was edited, removed or reordered.
+//
+//	go run ./cmd/auditverify
+//
+// DB_URL is read from the environment or .env, same as the server.
+// the printed head hash is worth keeping somewhere else: a chain can't tell on its own
+// whether events were cut off the end
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/audit"
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+const verifyBatchSize = 1000
+
+func main() {
+	godotenv.Load()
+	db, err := sql.Open("postgres", os.Getenv("DB_URL"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "auditverify: %s\n", err)
+		os.Exit(2)
+	}
+	defer db.Close()
+
+	head, count, err := verifyChain(context.Background(), database.New(db))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "auditverify: %s\n", err)
+		fmt.Fprintf(os.Stderr, "auditverify: %d events verified before the failure, last good hash %q\n", count, head)
+		os.Exit(1)
+	}
+	fmt.Printf("audit chain ok: %d events, head %s\n", count, head)
+}
+
+func verifyChain(ctx context.Context, q *database.Queries) (string, int64, error) {
+	verifier := audit.Verifier{}
+	lastID := int64(0)
+	for {
+		rows, err := q.GetAuditEventsAfter(ctx, database.GetAuditEventsAfterParams{ID: lastID, Limit: verifyBatchSize})
+		if err != nil {
+			head, count := verifier.Head()
+			return head, count, err
+		}
+		for _, row := range rows {
+			details := map[string]string{}
+			if err := json.Unmarshal(row.Details, &details); err != nil {
+				head, count := verifier.Head()
+				return head, count, fmt.Errorf("event %d: unreadable details: %w", row.ID, err)
+			}
+			event := audit.Event{
+				CreatedAt:  row.CreatedAt,
+				Action:     row.Action,
+				ActorID:    row.ActorID,
+				TargetType: row.TargetType,
+				TargetID:   row.TargetID,
+				IP:         row.Ip,
+				UserAgent:  row.UserAgent,
+				Details:    details,
+			}
+			if err := verifier.Check(row.ID, row.PrevHash, row.Hash, event); err != nil {
+				head, count := verifier.Head()
+				return head, count, err
+			}
+			lastID = row.ID
+		}
+		if len(rows) < verifyBatchSize {
+			head, count := verifier.Head()
+			return head, count, nil
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/JettMingin/chirpy-bootdev/internal/logging"
+	"github.com/JettMingin/chirpy-bootdev/internal/moderation"
+	"github.com/google/uuid"
+)
+
+const (
+	filterRulesChannel   = "filter_rules"
+	reportReasonFilter   = "filter"
+	maxFilterPatternSize = 100
+)
+
+// what the filter starts with until the rules have been loaded from the DB
+var defaultFilterRules = []moderation.Rule{
+	{Pattern: "kerfuffle", Actions: []moderation.Action{moderation.ActionMask}},
+	{Pattern: "sharbert", Actions: []moderation.Action{moderation.ActionMask}},
+	{Pattern: "fornax", Actions: []moderation.Action{moderation.ActionMask}},
+}
+
+type FilterRule struct {
+	ID        uuid.UUID           `json:"id"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+	Pattern   string              `json:"pattern"`
+	Locale    string              `json:"locale"`
+	Actions   []moderation.Action `json:"actions"`
+	CreatedBy *uuid.UUID          `json:"created_by"`
+}
+
+func filterRuleFromDB(dbRule database.FilterRule) FilterRule {
+	actions := []moderation.Action{}
+	for _, action := range dbRule.Actions {
+		actions = append(actions, moderation.Action(action))
+	}
+	return FilterRule{
+		ID:        dbRule.ID,
+		CreatedAt: dbRule.CreatedAt,
+		UpdatedAt: dbRule.UpdatedAt,
+		Pattern:   dbRule.Pattern,
+		Locale:    dbRule.Locale,
+		Actions:   actions,
+		CreatedBy: nullUUIDPtr(dbRule.CreatedBy),
+	}
+}
+
+// rebuilds the matcher from filter_rules. called at startup, after admin edits and whenever
+// another instance changes the rules (filter_rules NOTIFY)
+func (cfg *apiConfig) reloadContentFilter(ctx context.Context) error {
+	dbRules, err := cfg.DB.GetFilterRules(ctx)
+	if err != nil {
+		return err
+	}
+	rules := []moderation.Rule{}
+	for _, dbRule := range dbRules {
+		rule := filterRuleFromDB(dbRule)
+		rules = append(rules, moderation.Rule{
+			ID:      rule.ID,
+			Pattern: rule.Pattern,
+			Locale:  rule.Locale,
+			Actions: rule.Actions,
+		})
+	}
+	cfg.ContentFilter.Load(rules)
+	return nil
+}
+
+// chirps say what language they're in with Content-Language, without it only the global rules apply
+func requestLocale(req *http.Request) string {
+	locale, _, _ := strings.Cut(req.Header.Get("Content-Language"), ",")
+	return strings.TrimSpace(locale)
+}
+
+// files a report with no reporter so a flagged chirp lands in the moderation queue
+func flagChirpForReview(ctx context.Context, qtx *database.Queries, dbChirp database.Chirp, filtered moderation.Result) error {
+	patterns := []string{}
+	for _, match := range filtered.Matches {
+		if !slices.Contains(patterns, match.Rule.Pattern) {
+			patterns = append(patterns, match.Rule.Pattern)
+		}
+	}
+	_, err := qtx.CreateReport(ctx, database.CreateReportParams{
+		ChirpID:      uuid.NullUUID{UUID: dbChirp.ID, Valid: true},
+		ChirpBody:    sql.NullString{String: dbChirp.Body, Valid: true},
+		TargetUserID: uuid.NullUUID{UUID: dbChirp.UserID, Valid: true},
+		Reason:       reportReasonFilter,
+		Details:      "matched filter rules: " + strings.Join(patterns, ", "),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+// decodes and validates {pattern, locale, actions}, writing the error response itself when the body is unusable
+func readFilterRuleRequest(res http.ResponseWriter, req *http.Request) (FilterRule, bool) {
+	reqData, err := io.ReadAll(req.Body)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to read request body", err, 500)
+		return FilterRule{}, false
+	}
+	var ruleReq FilterRule
+	if err := json.Unmarshal(reqData, &ruleReq); err != nil {
+		ErrorResponseWriter(res, "Failed to decode request body", err, 400)
+		return FilterRule{}, false
+	}
+	ruleReq.Pattern = strings.TrimSpace(ruleReq.Pattern)
+	if moderation.Normalize(ruleReq.Pattern) == "" || len(ruleReq.Pattern) > maxFilterPatternSize {
+		err := errors.New("missing or invalid pattern field")
+		ErrorResponseWriter(res, "pattern missing from body or longer than 100 characters", err, 400)
+		return FilterRule{}, false
+	}
+	ruleReq.Locale = strings.ToLower(strings.TrimSpace(ruleReq.Locale))
+	if len(ruleReq.Locale) > 16 {
+		err := errors.New("invalid locale field")
+		ErrorResponseWriter(res, "locale must be a language tag like 'en' or empty for every locale", err, 400)
+		return FilterRule{}, false
+	}
+	if len(ruleReq.Actions) == 0 {
+		err := errors.New("missing actions field")
+		ErrorResponseWriter(res, "actions must list at least one of mask, reject or flag", err, 400)
+		return FilterRule{}, false
+	}
+	for _, action := range ruleReq.Actions {
+		if !slices.Contains(moderation.Actions, action) {
+			err := errors.New("unknown filter action")
+			ErrorResponseWriter(res, "actions must only contain mask, reject or flag", err, 400)
+			return FilterRule{}, false
+		}
+	}
+	return ruleReq, true
+}
+
+func filterRuleActions(rule FilterRule) []string {
+	actions := []string{}
+	for _, action := range rule.Actions {
+		if !slices.Contains(actions, string(action)) {
+			actions = append(actions, string(action))
+		}
+	}
+	return actions
+}
+
+// the local instance rebuilds straight away, the others pick the change up from the NOTIFY
+func (cfg *apiConfig) writeFilterRule(res http.ResponseWriter, req *http.Request, dbRule database.FilterRule, statusCode int) {
+	if err := cfg.reloadContentFilter(req.Context()); err != nil {
+		logging.FromContext(req.Context()).Error("reloading content filter", "error", err)
+	}
+	successRes, err := json.Marshal(filterRuleFromDB(dbRule))
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(statusCode)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) GetFilterRules(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	if _, ok := cfg.getModeratorID(res, req); !ok {
+		return
+	}
+	dbRules, err := cfg.DB.GetFilterRules(req.Context())
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for filter rules in DB", err, 500)
+		return
+	}
+
+	selectedRules := []FilterRule{}
+	for _, row := range dbRules {
+		selectedRules = append(selectedRules, filterRuleFromDB(row))
+	}
+
+	successRes, err := json.Marshal(selectedRules)
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) PostFilterRule(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	moderatorID, ok := cfg.getModeratorID(res, req)
+	if !ok {
+		return
+	}
+	ruleReq, ok := readFilterRuleRequest(res, req)
+	if !ok {
+		return
+	}
+
+	dbRule, err := cfg.DB.CreateFilterRule(req.Context(), database.CreateFilterRuleParams{
+		Pattern:   ruleReq.Pattern,
+		Locale:    ruleReq.Locale,
+		Actions:   filterRuleActions(ruleReq),
+		CreatedBy: uuid.NullUUID{UUID: moderatorID, Valid: true},
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to write filter rule to DB (pattern and locale must be unique)", err, 409)
+		return
+	}
+	cfg.writeFilterRule(res, req, dbRule, 201)
+}
+
+func (cfg *apiConfig) UpdateFilterRule(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	if _, ok := cfg.getModeratorID(res, req); !ok {
+		return
+	}
+	ruleID, err := uuid.Parse(req.PathValue("ruleId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+	ruleReq, ok := readFilterRuleRequest(res, req)
+	if !ok {
+		return
+	}
+
+	dbRule, err := cfg.DB.UpdateFilterRule(req.Context(), database.UpdateFilterRuleParams{
+		Pattern: ruleReq.Pattern,
+		Locale:  ruleReq.Locale,
+		Actions: filterRuleActions(ruleReq),
+		ID:      ruleID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		ErrorResponseWriter(res, "failed to find filter rule with provided id in DB", err, 404)
+		return
+	}
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to update filter rule in DB (pattern and locale must be unique)", err, 409)
+		return
+	}
+	cfg.writeFilterRule(res, req, dbRule, 200)
+}
+
+func (cfg *apiConfig) DeleteFilterRule(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	if _, ok := cfg.getModeratorID(res, req); !ok {
+		return
+	}
+	ruleID, err := uuid.Parse(req.PathValue("ruleId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+
+	deleted, err := cfg.DB.DeleteFilterRule(req.Context(), ruleID)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to delete filter rule in DB", err, 500)
+		return
+	}
+	if deleted == 0 {
+		err := errors.New("filter rule not found")
+		ErrorResponseWriter(res, "failed to find filter rule with provided id in DB", err, 404)
+		return
+	}
+	if err := cfg.reloadContentFilter(req.Context()); err != nil {
+		logging.FromContext(req.Context()).Error("reloading content filter", "error", err)
+	}
+	res.WriteHeader(204)
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxConversationSize = 10
+	maxMessageLength    = 2000
+)
+
+type Conversation struct {
+	ID           uuid.UUID                 `json:"id"`
+	CreatedAt    time.Time                 `json:"created_at"`
+	UpdatedAt    time.Time                 `json:"updated_at"`
+	CreatedBy    *uuid.UUID                `json:"created_by"`
+	Participants []ConversationParticipant `json:"participants"`
+	LastMessage  *Message                  `json:"last_message"`
+	UnreadCount  int64                     `json:"unread_count"`
+}
+
+// last_read_at doubles as the read receipt, every message sent before it has been seen
+type ConversationParticipant struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	JoinedAt   time.Time  `json:"joined_at"`
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+type Message struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+}
+
+func messageFromDB(dbMessage database.Message) Message {
+	return Message{
+		ID:             dbMessage.ID,
+		CreatedAt:      dbMessage.CreatedAt,
+		ConversationID: dbMessage.ConversationID,
+		SenderID:       dbMessage.SenderID,
+		Body:           dbMessage.Body,
+	}
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// fills in participants for a page of conversations with one query
+func (cfg *apiConfig) attachParticipants(req *http.Request, conversations []Conversation) error {
+	conversationIDs := []uuid.UUID{}
+	for _, conversation := range conversations {
+		conversationIDs = append(conversationIDs, conversation.ID)
+	}
+	dbParticipants, err := cfg.DB.GetConversationsParticipants(req.Context(), conversationIDs)
+	if err != nil {
+		return err
+	}
+	participants := map[uuid.UUID][]ConversationParticipant{}
+	for _, row := range dbParticipants {
+		participants[row.ConversationID] = append(participants[row.ConversationID], ConversationParticipant{
+			UserID:     row.UserID,
+			JoinedAt:   row.JoinedAt,
+			LastReadAt: nullTimePtr(row.LastReadAt),
+		})
+	}
+	for i := range conversations {
+		conversations[i].Participants = participants[conversations[i].ID]
+	}
+	return nil
+}
+
+// messages are only visible to participants, anyone else gets a 404 so conversation ids don't leak
+func (cfg *apiConfig) getParticipantConversationID(req *http.Request, userID uuid.UUID) (uuid.UUID, error) {
+	conversationID, err := uuid.Parse(req.PathValue("conversationId"))
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	if _, err := cfg.DB.GetConversationParticipant(req.Context(), database.GetConversationParticipantParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	}); err != nil {
+		return uuid.UUID{}, err
+	}
+	return conversationID, nil
+}
+
+func (cfg *apiConfig) PostConversation(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+
+	type ConversationRequest struct {
+		ParticipantIDs []uuid.UUID `json:"participant_ids"`
+	}
+	reqData, err := io.ReadAll(req.Body)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to read request body", err, 500)
+		return
+	}
+	var conversationReq ConversationRequest
+	if err := json.Unmarshal(reqData, &conversationReq); err != nil {
+		ErrorResponseWriter(res, "Failed to decode request body", err, 400)
+		return
+	}
+
+	participantIDs := []uuid.UUID{userID}
+	for _, participantID := range conversationReq.ParticipantIDs {
+		if !slices.Contains(participantIDs, participantID) {
+			participantIDs = append(participantIDs, participantID)
+		}
+	}
+	if len(participantIDs) < 2 || len(participantIDs) > maxConversationSize {
+		err := errors.New("invalid participant_ids field")
+		ErrorResponseWriter(res, fmt.Sprintf("a conversation needs between 2 and %d participants, including you", maxConversationSize), err, 400)
+		return
+	}
+	for _, participantID := range participantIDs[1:] {
+		if _, err := cfg.DB.GetUser(req.Context(), participantID); err != nil {
+			ErrorResponseWriter(res, "failed to find participant with provided id in DB", err, 404)
+			return
+		}
+	}
+	blocked, err := cfg.DB.IsBlockedByAny(req.Context(),
+		database.IsBlockedByAnyParams{BlockerIds: participantIDs[1:], BlockedID: userID})
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for blocks in DB", err, 500)
+		return
+	}
+	if blocked {
+		err := errors.New("blocked by a participant")
+		ErrorResponseWriter(res, "you can't start a conversation with someone who blocked you", err, 403)
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to start DB transaction", err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	//a 1:1 conversation is reused if the two users already have one. the unique direct_key makes
+	//a concurrent request for the same pair wait for this insert, then get this row back
+	var dbConversation database.Conversation
+	created := true
+	if len(participantIDs) == 2 {
+		dbDirect, err := qtx.UpsertDirectConversation(req.Context(), database.UpsertDirectConversationParams{
+			CreatedBy: uuid.NullUUID{UUID: userID, Valid: true},
+			DirectKey: sql.NullString{String: directConversationKey(participantIDs[0], participantIDs[1]), Valid: true},
+		})
+		if err != nil {
+			ErrorResponseWriter(res, "Failed to write conversation to DB", err, 500)
+			return
+		}
+		dbConversation = database.Conversation{
+			ID:            dbDirect.ID,
+			CreatedAt:     dbDirect.CreatedAt,
+			UpdatedAt:     dbDirect.UpdatedAt,
+			CreatedBy:     dbDirect.CreatedBy,
+			LastMessageAt: dbDirect.LastMessageAt,
+			DirectKey:     dbDirect.DirectKey,
+		}
+		created = dbDirect.Created
+	} else {
+		dbConversation, err = qtx.CreateConversation(req.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+		if err != nil {
+			ErrorResponseWriter(res, "Failed to write conversation to DB", err, 500)
+			return
+		}
+	}
+	if !created {
+		tx.Rollback()
+		cfg.writeConversation(res, req, dbConversation, 200)
+		return
+	}
+	for _, participantID := range participantIDs {
+		if err := qtx.AddConversationParticipant(req.Context(), database.AddConversationParticipantParams{
+			ConversationID: dbConversation.ID,
+			UserID:         participantID,
+		}); err != nil {
+			ErrorResponseWriter(res, "Failed to write conversation participant to DB", err, 500)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		ErrorResponseWriter(res, "Failed to commit DB transaction", err, 500)
+		return
+	}
+	cfg.writeConversation(res, req, dbConversation, 201)
+}
+
+// the two user ids in a fixed order, the same key 031_conversation_direct_key.sql backfilled
+func directConversationKey(userA, userB uuid.UUID) string {
+	ids := []string{userA.String(), userB.String()}
+	slices.Sort(ids)
+	return ids[0] + ":" + ids[1]
+}
+
+func (cfg *apiConfig) writeConversation(res http.ResponseWriter, req *http.Request, dbConversation database.Conversation, statusCode int) {
+	conversations := []Conversation{{
+		ID:        dbConversation.ID,
+		CreatedAt: dbConversation.CreatedAt,
+		UpdatedAt: dbConversation.UpdatedAt,
+		CreatedBy: nullUUIDPtr(dbConversation.CreatedBy),
+	}}
+	if err := cfg.attachParticipants(req, conversations); err != nil {
+		ErrorResponseWriter(res, "failed to query for conversation participants in DB", err, 500)
+		return
+	}
+
+	successRes, err := json.Marshal(conversations[0])
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(statusCode)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) GetConversations(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	limit, offset, err := getPaginationParams(req)
+	if err != nil {
+		ErrorResponseWriter(res, "invalid pagination params", err, 400)
+		return
+	}
+
+	dbConversations, err := cfg.DB.GetUsersConversations(req.Context(),
+		database.GetUsersConversationsParams{UserID: userID, Limit: limit, Offset: offset})
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for conversations in DB", err, 500)
+		return
+	}
+
+	selectedConversations := []Conversation{}
+	for _, row := range dbConversations {
+		conversation := Conversation{
+			ID:          row.ID,
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+			CreatedBy:   nullUUIDPtr(row.CreatedBy),
+			UnreadCount: row.UnreadCount,
+		}
+		if row.LastMessageID.Valid {
+			conversation.LastMessage = &Message{
+				ID:             row.LastMessageID.UUID,
+				CreatedAt:      row.LastMessageCreatedAt.Time,
+				ConversationID: row.ID,
+				SenderID:       row.LastMessageSenderID.UUID,
+				Body:           row.LastMessageBody.String,
+			}
+		}
+		selectedConversations = append(selectedConversations, conversation)
+	}
+	if err := cfg.attachParticipants(req, selectedConversations); err != nil {
+		ErrorResponseWriter(res, "failed to query for conversation participants in DB", err, 500)
+		return
+	}
+
+	successRes, err := json.Marshal(selectedConversations)
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) PostMessage(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	conversationID, err := cfg.getParticipantConversationID(req, userID)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to find conversation with provided id", err, 404)
+		return
+	}
+
+	reqData, err := io.ReadAll(req.Body)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to read request body", err, 500)
+		return
+	}
+	var messageReq map[string]string
+	if err := json.Unmarshal(reqData, &messageReq); err != nil {
+		ErrorResponseWriter(res, "Failed to decode request body", err, 400)
+		return
+	}
+	messageBody := strings.TrimSpace(messageReq["body"])
+	if messageBody == "" || len(messageBody) > maxMessageLength {
+		err := errors.New("missing or invalid body field")
+		ErrorResponseWriter(res, fmt.Sprintf("Request Body missing 'body' field or longer than %d characters", maxMessageLength), err, 400)
+		return
+	}
+	blocked, err := cfg.DB.IsBlockedInConversation(req.Context(),
+		database.IsBlockedInConversationParams{ConversationID: conversationID, BlockedID: userID})
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for blocks in DB", err, 500)
+		return
+	}
+	if blocked {
+		err := errors.New("blocked by a participant")
+		ErrorResponseWriter(res, "a participant in this conversation has blocked you", err, 403)
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to start DB transaction", err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	dbMessage, err := qtx.CreateMessage(req.Context(), database.CreateMessageParams{
+		ConversationID: conversationID,
+		SenderID:       userID,
+		Body:           messageBody,
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to write message to DB", err, 500)
+		return
+	}
+	if err := qtx.TouchConversation(req.Context(), database.TouchConversationParams{
+		LastMessageAt: sql.NullTime{Time: dbMessage.CreatedAt, Valid: true},
+		ID:            conversationID,
+	}); err != nil {
+		ErrorResponseWriter(res, "Failed to update conversation in DB", err, 500)
+		return
+	}
+	//sending a message means you've read everything before it
+	if err := qtx.MarkConversationRead(req.Context(),
+		database.MarkConversationReadParams{ConversationID: conversationID, UserID: userID}); err != nil {
+		ErrorResponseWriter(res, "Failed to update read receipt in DB", err, 500)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		ErrorResponseWriter(res, "Failed to commit DB transaction", err, 500)
+		return
+	}
+
+	successRes, err := json.Marshal(messageFromDB(dbMessage))
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(201)
+	res.Write(successRes)
+}
+
+// newest first, page backwards with ?offset=
+func (cfg *apiConfig) GetMessages(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	conversationID, err := cfg.getParticipantConversationID(req, userID)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to find conversation with provided id", err, 404)
+		return
+	}
+	limit, offset, err := getPaginationParams(req)
+	if err != nil {
+		ErrorResponseWriter(res, "invalid pagination params", err, 400)
+		return
+	}
+
+	dbMessages, err := cfg.DB.GetConversationMessages(req.Context(), database.GetConversationMessagesParams{
+		ConversationID: conversationID,
+		Limit:          limit,
+		Offset:         offset,
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for messages in DB", err, 500)
+		return
+	}
+
+	selectedMessages := []Message{}
+	for _, row := range dbMessages {
+		selectedMessages = append(selectedMessages, messageFromDB(row))
+	}
+
+	successRes, err := json.Marshal(selectedMessages)
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+// moves the caller's read receipt up to now
+func (cfg *apiConfig) MarkConversationRead(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	conversationID, err := cfg.getParticipantConversationID(req, userID)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to find conversation with provided id", err, 404)
+		return
+	}
+
+	if err := cfg.DB.MarkConversationRead(req.Context(),
+		database.MarkConversationReadParams{ConversationID: conversationID, UserID: userID}); err != nil {
+		ErrorResponseWriter(res, "Failed to update read receipt in DB", err, 500)
+		return
+	}
+	res.WriteHeader(204)
+}
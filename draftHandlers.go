@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+)
+
+// drafts skip the chirp length limit until publish, this just stops autosave from storing novels
+const maxDraftLength = 10000
+
+type Draft struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+	UserID    uuid.UUID `json:"user_id"`
+}
+
+func draftFromDB(dbDraft database.Draft) Draft {
+	return Draft{
+		ID:        dbDraft.ID,
+		CreatedAt: dbDraft.CreatedAt,
+		UpdatedAt: dbDraft.UpdatedAt,
+		Body:      dbDraft.Body,
+		UserID:    dbDraft.UserID,
+	}
+}
+
+// reads {"body": "..."} for create and autosave. an empty body is a valid draft
+func readDraftBody(req *http.Request) (string, int, error) {
+	reqData, err := io.ReadAll(req.Body)
+	if err != nil {
+		return "", 500, err
+	}
+	var draftReq map[string]string
+	if err := json.Unmarshal(reqData, &draftReq); err != nil {
+		return "", 400, err
+	}
+	if len(draftReq["body"]) > maxDraftLength {
+		return "", 400, errors.New("draft body is too long")
+	}
+	return draftReq["body"], 0, nil
+}
+
+func (cfg *apiConfig) PostDraft(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	draftBody, statusCode, err := readDraftBody(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to read draft from request body", err, statusCode)
+		return
+	}
+
+	dbDraft, err := cfg.DB.CreateDraft(req.Context(),
+		database.CreateDraftParams{Body: draftBody, UserID: userID})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to write new draft to DB", err, 500)
+		return
+	}
+
+	successRes, err := json.Marshal(draftFromDB(dbDraft))
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(201)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) GetDrafts(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	dbDrafts, err := cfg.DB.GetUsersDrafts(req.Context(), userID)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for drafts in DB", err, 500)
+		return
+	}
+
+	selectedDrafts := []Draft{}
+	for _, row := range dbDrafts {
+		selectedDrafts = append(selectedDrafts, draftFromDB(row))
+	}
+
+	successRes, err := json.Marshal(selectedDrafts)
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) GetDraft(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	draftID, err := uuid.Parse(req.PathValue("draftId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+
+	//other users' drafts come back as 404, not 403, so ids can't be probed
+	dbDraft, err := cfg.DB.GetDraft(req.Context(), database.GetDraftParams{ID: draftID, UserID: userID})
+	if err != nil {
+		ErrorResponseWriter(res, "failed to find draft with provided id in DB", err, 404)
+		return
+	}
+
+	successRes, err := json.Marshal(draftFromDB(dbDraft))
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) UpdateDraft(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	draftID, err := uuid.Parse(req.PathValue("draftId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+	draftBody, statusCode, err := readDraftBody(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to read draft from request body", err, statusCode)
+		return
+	}
+
+	dbDraft, err := cfg.DB.UpdateDraft(req.Context(),
+		database.UpdateDraftParams{Body: draftBody, ID: draftID, UserID: userID})
+	if err != nil {
+		ErrorResponseWriter(res, "failed to find draft with provided id in DB", err, 404)
+		return
+	}
+
+	successRes, err := json.Marshal(draftFromDB(dbDraft))
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) DeleteDraft(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	draftID, err := uuid.Parse(req.PathValue("draftId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+
+	deleted, err := cfg.DB.DeleteDraft(req.Context(), database.DeleteDraftParams{ID: draftID, UserID: userID})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to delete draft in DB", err, 500)
+		return
+	}
+	if deleted == 0 {
+		err := errors.New("draft not found")
+		ErrorResponseWriter(res, "failed to find draft with provided id in DB", err, 404)
+		return
+	}
+	res.WriteHeader(204)
+}
+
+func (cfg *apiConfig) PublishDraft(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	draftID, err := uuid.Parse(req.PathValue("draftId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to start DB transaction", err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	//row lock so a double-tapped publish can't create two chirps
+	dbDraft, err := qtx.GetDraftForUpdate(req.Context(),
+		database.GetDraftForUpdateParams{ID: draftID, UserID: userID})
+	if err != nil {
+		ErrorResponseWriter(res, "failed to find draft with provided id in DB", err, 404)
+		return
+	}
+
+	entitlements, err := cfg.getEntitlements(req.Context(), userID)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to look up user entitlements", err, 500)
+		return
+	}
+	filtered, isValid := cfg.validateChirpHelper(dbDraft.Body, entitlements.MaxChirpLength, requestLocale(req))
+	if !isValid {
+		err := errors.New("invalid draft body")
+		ErrorResponseWriter(res, fmt.Sprintf("Draft body must be between 1 and %d characters to publish", entitlements.MaxChirpLength), err, 400)
+		return
+	}
+	if filtered.Reject {
+		err := errors.New("draft matched a reject filter rule")
+		ErrorResponseWriter(res, "Draft contains content that isn't allowed", err, 400)
+		return
+	}
+
+	dbChirp, rejected, err := createScoredChirp(req.Context(), qtx,
+		database.CreateChirpParams{Body: filtered.Text, UserID: userID})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to write new chirp to DB", err, 500)
+		return
+	}
+	//the draft is kept, only the decision is committed
+	if rejected {
+		if err := tx.Commit(); err != nil {
+			ErrorResponseWriter(res, "Failed to commit DB transaction", err, 500)
+			return
+		}
+		err := errors.New("draft scored over the spam reject threshold")
+		ErrorResponseWriter(res, "Draft was rejected as spam", err, 403)
+		return
+	}
+	if filtered.Flag {
+		if err := flagChirpForReview(req.Context(), qtx, dbChirp, filtered); err != nil {
+			ErrorResponseWriter(res, "Failed to flag chirp for review", err, 500)
+			return
+		}
+	}
+	if _, err := qtx.DeleteDraft(req.Context(),
+		database.DeleteDraftParams{ID: dbDraft.ID, UserID: userID}); err != nil {
+		ErrorResponseWriter(res, "Failed to delete published draft in DB", err, 500)
+		return
+	}
+	//shadow limited chirps are announced if a moderator releases them
+	if !dbChirp.ShadowLimitedAt.Valid {
+		if err := enqueueWebhookEvent(req.Context(), qtx, webhookChirpCreated, chirpFromDB(dbChirp)); err != nil {
+			ErrorResponseWriter(res, "Failed to enqueue webhook event", err, 500)
+			return
+		}
+		if err := notifyMentions(req.Context(), qtx, dbChirp, uuid.NullUUID{}); err != nil {
+			ErrorResponseWriter(res, "Failed to write notification to DB", err, 500)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		ErrorResponseWriter(res, "Failed to commit DB transaction", err, 500)
+		return
+	}
+	cfg.Metrics.chirpsCreated.WithLabelValues(chirpSourceDraft).Inc()
+
+	newChirp := Chirp{
+		ID:        dbChirp.ID,
+		CreatedAt: dbChirp.CreatedAt,
+		UpdatedAt: dbChirp.UpdatedAt,
+		Body:      dbChirp.Body,
+		UserID:    dbChirp.UserID,
+		ReplyToID: nullUUIDPtr(dbChirp.ReplyToID),
+	}
+	successRes, err := json.Marshal(newChirp)
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(201)
+	res.Write(successRes)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	chirpyRedPlan   = "chirpy_red"
+	chirpyRedPeriod = 30 * 24 * time.Hour
+)
+
+// what a user is allowed to do. handlers ask getEntitlements instead of reading users.is_chirpy_red,
+// which is only kept around as a cached copy for the User JSON
+type Entitlements struct {
+	Plan                string
+	MaxChirpLength      int
+	CanEditChirps       bool
+	BookmarkFolders     bool
+	MaxPollDuration     time.Duration
+	RateLimitMultiplier int
+}
+
+var freeEntitlements = Entitlements{
+	Plan:                "free",
+	MaxChirpLength:      140,
+	CanEditChirps:       false,
+	BookmarkFolders:     false,
+	MaxPollDuration:     maxPollDuration,
+	RateLimitMultiplier: 1,
+}
+
+var chirpyRedEntitlements = Entitlements{
+	Plan:                chirpyRedPlan,
+	MaxChirpLength:      280,
+	CanEditChirps:       true,
+	BookmarkFolders:     true,
+	MaxPollDuration:     maxRedPollDuration,
+	RateLimitMultiplier: 5,
+}
+
+func (cfg *apiConfig) getEntitlements(ctx context.Context, userID uuid.UUID) (Entitlements, error) {
+	_, err := cfg.DB.GetActiveSubscription(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return freeEntitlements, nil
+	}
+	if err != nil {
+		return Entitlements{}, err
+	}
+	return chirpyRedEntitlements, nil
+}
+
+// background job started from main, flips lapsed subscriptions to expired and
+// drops the cached is_chirpy_red flag for those users
+func (cfg *apiConfig) runSubscriptionExpirer(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cfg.expireSubscriptions(ctx); err != nil {
+				slog.Error("expiring subscriptions", "error", err)
+			}
+		}
+	}
+}
+
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context) error {
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	expiredUserIDs, err := qtx.ExpireSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, userID := range expiredUserIDs {
+		if err := qtx.SyncUserChirpyRed(ctx, userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+)
+
+// following a user puts their chirps on your timeline, GET /api/stream?follows=true
+
+func (cfg *apiConfig) FollowUser(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	targetID, ok := cfg.getTargetUserID(res, req, userID)
+	if !ok {
+		return
+	}
+	blocked, err := cfg.DB.IsBlockedByAny(req.Context(),
+		database.IsBlockedByAnyParams{BlockerIds: []uuid.UUID{targetID}, BlockedID: userID})
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for blocks in DB", err, 500)
+		return
+	}
+	if blocked {
+		err := errors.New("blocked by the followed user")
+		ErrorResponseWriter(res, "you can't follow someone who blocked you", err, 403)
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to start DB transaction", err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	created, err := qtx.CreateUserFollow(req.Context(),
+		database.CreateUserFollowParams{FollowerID: userID, FollowedID: targetID})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to write follow to DB", err, 500)
+		return
+	}
+	//following again is a no-op and isn't announced twice
+	if created > 0 {
+		if err := enqueuePersonalWebhookEvent(req.Context(), qtx, webhookFollowCreated, webhookFollow{
+			FollowerID: userID,
+			FollowedID: targetID,
+			CreatedAt:  time.Now().UTC(),
+		}, targetID); err != nil {
+			ErrorResponseWriter(res, "Failed to queue webhook event", err, 500)
+			return
+		}
+		if err := notifyUser(req.Context(), qtx, targetID, notifyUserFollowed,
+			uuid.NullUUID{}, uuid.NullUUID{UUID: userID, Valid: true}); err != nil {
+			ErrorResponseWriter(res, "Failed to write notification to DB", err, 500)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		ErrorResponseWriter(res, "Failed to commit DB transaction", err, 500)
+		return
+	}
+	res.WriteHeader(204)
+}
+
+func (cfg *apiConfig) UnfollowUser(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	targetID, err := uuid.Parse(req.PathValue("userId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+
+	deleted, err := cfg.DB.DeleteUserFollow(req.Context(),
+		database.DeleteUserFollowParams{FollowerID: userID, FollowedID: targetID})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to delete follow in DB", err, 500)
+		return
+	}
+	if deleted == 0 {
+		err := errors.New("follow not found")
+		ErrorResponseWriter(res, "you are not following this user", err, 404)
+		return
+	}
+	res.WriteHeader(204)
+}
+
+// the users a viewer follows, for the follows-only stream. loaded once when the stream opens like
+// the hidden authors, so a follow made mid-stream shows up after the client reconnects
+func (cfg *apiConfig) getFollowedAuthors(req *http.Request, viewerID uuid.UUID) (map[uuid.UUID]bool, error) {
+	followedIDs, err := cfg.DB.GetFollowedIDs(req.Context(), viewerID)
+	if err != nil {
+		return nil, err
+	}
+	followedAuthors := map[uuid.UUID]bool{}
+	for _, followedID := range followedIDs {
+		followedAuthors[followedID] = true
+	}
+	return followedAuthors, nil
+}
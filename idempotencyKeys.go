@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/apierror"
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/JettMingin/chirpy-bootdev/internal/idempotency"
+	"github.com/JettMingin/chirpy-bootdev/internal/logging"
+)
+
+// how long a key's response is kept and replayed
+const idempotencyKeyTTL = 24 * time.Hour
+
+// wraps a create endpoint so a request sent with an Idempotency-Key runs at most once.
+// a retry with the same key and body gets the first response back, the same key with a
+// different body is a 422. requests without the header are handled as usual.
+//
+// the handler writes in its own transaction, which commits before the response is stored here.
+// if the process dies between those two commits the key is freed and a retry runs the handler again.
+// a client that hangs up doesn't: the key's transaction ignores the request being cancelled
+func (cfg *apiConfig) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		key := req.Header.Get(idempotency.Header)
+		if key == "" {
+			next(res, req)
+			return
+		}
+		if !idempotency.ValidKey(key) {
+			err := errors.New("malformed idempotency key")
+			writeAPIError(res, req, apierror.BadRequest(apierror.CodeValidationFailed,
+				"Idempotency-Key must be 1 to 255 printable ASCII characters", err))
+			return
+		}
+		reqData, err := io.ReadAll(req.Body)
+		if err != nil {
+			writeAPIError(res, req, apierror.ReadBody(err))
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqData))
+
+		//without a token, keys are kept apart per client ip rather than shared by every anonymous caller
+		scope := "ip:" + cfg.clientIP(req)
+		if userID, err := cfg.getAuthedUserID(req); err == nil {
+			scope = "user:" + userID.String()
+		}
+		fingerprint := idempotency.Fingerprint(req.Method, req.URL.Path, reqData)
+
+		//database/sql rolls a transaction back when its context is cancelled, and a client dropping
+		//the connection cancels req.Context() after the handler's own writes may have committed
+		ctx := context.WithoutCancel(req.Context())
+		tx, err := cfg.DBConn.BeginTx(ctx, nil)
+		if err != nil {
+			writeAPIError(res, req, apierror.Internal("Failed to start DB transaction", err))
+			return
+		}
+		defer tx.Rollback()
+		qtx := cfg.withTx(tx)
+
+		//a concurrent request with the same key blocks on the primary key until this one commits,
+		//then gets no row back and replays what this one stored
+		_, err = qtx.ClaimIdempotencyKey(ctx, database.ClaimIdempotencyKeyParams{
+			Scope:       scope,
+			Key:         key,
+			TtlSeconds:  idempotencyKeyTTL.Seconds(),
+			RequestHash: fingerprint,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			tx.Rollback()
+			cfg.replayIdempotentResponse(res, req, scope, key, fingerprint)
+			return
+		}
+		if err != nil {
+			writeAPIError(res, req, apierror.Internal("Failed to claim idempotency key", err))
+			return
+		}
+
+		recorder := idempotency.NewRecorder(res)
+		next(recorder, req)
+		//server errors aren't kept, rolling back frees the key for the client's retry
+		if recorder.Status >= 500 {
+			return
+		}
+		//the response is already out, a failure here only means a retry runs the handler again
+		logger := logging.FromContext(ctx)
+		if err := qtx.SaveIdempotentResponse(ctx, database.SaveIdempotentResponseParams{
+			Scope:        scope,
+			Key:          key,
+			StatusCode:   sql.NullInt32{Int32: int32(recorder.Status), Valid: true},
+			ContentType:  res.Header().Get("Content-Type"),
+			ResponseBody: recorder.Body.Bytes(),
+		}); err != nil {
+			logger.Error("saving idempotent response", "error", err)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			logger.Error("saving idempotent response", "error", err)
+		}
+	}
+}
+
+func (cfg *apiConfig) replayIdempotentResponse(res http.ResponseWriter, req *http.Request, scope, key, fingerprint string) {
+	stored, err := cfg.DB.GetIdempotencyKey(req.Context(), database.GetIdempotencyKeyParams{Scope: scope, Key: key})
+	if errors.Is(err, sql.ErrNoRows) {
+		//expired and pruned between the claim and this read
+		writeAPIError(res, req, apierror.New(http.StatusConflict, apierror.CodeConflict,
+			"the idempotency key changed state, retry the request", err))
+		return
+	}
+	if err != nil {
+		writeAPIError(res, req, apierror.Internal("Failed to look up idempotency key", err))
+		return
+	}
+	if stored.RequestHash != fingerprint {
+		err := errors.New("idempotency key reused with a different request")
+		writeAPIError(res, req, apierror.New(http.StatusUnprocessableEntity, apierror.CodeIdempotencyReused,
+			"this Idempotency-Key was already used for a different request", err))
+		return
+	}
+	idempotency.Replay(res, int(stored.StatusCode.Int32), stored.ContentType, stored.ResponseBody)
+}
+
+// background job started from main, drops keys past their 24 hours
+func (cfg *apiConfig) runIdempotencyKeyPruner(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := cfg.DB.DeleteExpiredIdempotencyKeys(ctx); err != nil {
+				slog.Error("pruning idempotency keys", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/logging"
+)
+
+// RFC 7807 media type, every error response is sent as this
+const ContentType = "application/problem+json"
+
+// machine-readable error codes. clients switch on these, so they never change once shipped
+type Code string
+
+const (
+	CodeBadRequest         Code = "bad_request"
+	CodeInvalidJSON        Code = "invalid_json"
+	CodeBodyTooLarge       Code = "body_too_large"
+	CodeInvalidID          Code = "invalid_id"
+	CodeValidationFailed   Code = "validation_failed"
+	CodeContentRejected    Code = "content_rejected"
+	CodeUnauthorized       Code = "unauthorized"
+	CodeInvalidCredentials Code = "invalid_credentials"
+	CodeInvalidSignature   Code = "invalid_signature"
+	CodeForbidden          Code = "forbidden"
+	CodeAccountSuspended   Code = "account_suspended"
+	CodeChirpyRedRequired  Code = "chirpy_red_required"
+	CodeSpamRejected       Code = "spam_rejected"
+	CodeNotFound           Code = "not_found"
+	CodeConflict           Code = "conflict"
+	CodePreconditionFailed Code = "precondition_failed"
+	CodeUnprocessable      Code = "unprocessable"
+	CodeIdempotencyReused  Code = "idempotency_key_reused"
+	CodeRateLimited        Code = "rate_limited"
+	CodeInternal           Code = "internal_error"
+)
+
+// the generic code for a status, for errors that never got a more specific one
+func CodeForStatus(status int) Code {
+	switch status {
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusPreconditionFailed:
+		return CodePreconditionFailed
+	case http.StatusRequestEntityTooLarge:
+		return CodeBodyTooLarge
+	case http.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// an error a handler means to send to the client.
+// Detail is written for the client, Err is the underlying cause and only ever reaches the logs.
+// 5xx details are logged but not sent either, they describe our internals
+type Error struct {
+	Status int
+	Code   Code
+	Detail string
+	Err    error
+}
+
+func New(status int, code Code, detail string, err error) *Error {
+	return &Error{Status: status, Code: code, Detail: detail, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+	}
+	return fmt.Sprintf("%s: %v", e.Code, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func BadRequest(code Code, detail string, err error) *Error {
+	return New(http.StatusBadRequest, code, detail, err)
+}
+
+func Unauthorized(code Code, detail string, err error) *Error {
+	return New(http.StatusUnauthorized, code, detail, err)
+}
+
+func Forbidden(code Code, detail string, err error) *Error {
+	return New(http.StatusForbidden, code, detail, err)
+}
+
+func NotFound(detail string, err error) *Error {
+	return New(http.StatusNotFound, CodeNotFound, detail, err)
+}
+
+// detail says what we were doing when err happened, for the logs
+func Internal(detail string, err error) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, detail, err)
+}
+
+// for a request body that couldn't be read, usually because it went over a MaxBytesReader
+func ReadBody(err error) *Error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return New(http.StatusRequestEntityTooLarge, CodeBodyTooLarge,
+			fmt.Sprintf("request body is larger than %d bytes", tooLarge.Limit), err)
+	}
+	return BadRequest(CodeBadRequest, "failed to read request body", err)
+}
+
+// for a request body that isn't the JSON the endpoint expects
+func InvalidJSON(err error) *Error {
+	return BadRequest(CodeInvalidJSON, "request body is not valid JSON", err)
+}
+
+// anything that isn't already an *Error is an unexpected failure, a 500
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return Internal("unexpected error", err)
+}
+
+// the response body, "code" and "request_id" are extension members
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      Code   `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func (e *Error) Problem(instance string) Problem {
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(e.Status),
+		Status:   e.Status,
+		Detail:   e.Detail,
+		Instance: instance,
+		Code:     e.Code,
+	}
+	if e.Status >= 500 {
+		problem.Detail = ""
+	}
+	return problem
+}
+
+// writes err as problem+json. instance is normally the request path.
+// the request id is copied from the response header the logging middleware already set
+func Write(res http.ResponseWriter, instance string, err *Error) {
+	problem := err.Problem(instance)
+	problem.RequestID = res.Header().Get(logging.RequestIDHeader)
+	//only strings and ints, this can't fail
+	body, _ := json.Marshal(problem)
+	res.Header().Set("Content-Type", ContentType)
+	res.WriteHeader(err.Status)
+	res.Write(body)
+}
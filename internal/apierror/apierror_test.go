@@ -0,0 +1,97 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	//test1 - a 4xx becomes a problem+json body with its code and detail
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Type", "application/json")
+	rec.Header().Set("X-Request-ID", "req-1")
+	Write(rec, "/api/chirps", InvalidJSON(errors.New("unexpected EOF")))
+	if rec.Code != 400 || rec.Header().Get("Content-Type") != ContentType {
+		t.Fatalf("test-FAIL: expected a 400 problem+json, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var problem Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("test-FAIL: body isn't JSON: %v", err)
+	}
+	if problem.Code != CodeInvalidJSON || problem.Status != 400 || problem.Title != "Bad Request" ||
+		problem.Instance != "/api/chirps" || problem.RequestID != "req-1" || problem.Detail == "" {
+		t.Fatalf("test-FAIL: unexpected problem %+v", problem)
+	} else {
+		t.Logf("test-PASS: %+v", problem)
+	}
+
+	//test2 - the cause never reaches the client
+	if strings.Contains(rec.Body.String(), "unexpected EOF") {
+		t.Fatalf("test-FAIL: cause leaked into %s", rec.Body.String())
+	} else {
+		t.Logf("test-PASS: cause kept out of the body")
+	}
+
+	//test3 - a 5xx drops its detail too
+	rec = httptest.NewRecorder()
+	Write(rec, "", Internal("Failed to write new chirp to DB", errors.New("pq: connection refused")))
+	body := rec.Body.String()
+	if rec.Code != 500 || strings.Contains(body, "chirp") || strings.Contains(body, "pq:") || !strings.Contains(body, `"code":"internal_error"`) {
+		t.Fatalf("test-FAIL: expected a bare internal_error, got %d %s", rec.Code, body)
+	} else {
+		t.Logf("test-PASS: %s", body)
+	}
+}
+
+func TestFrom(t *testing.T) {
+	//test1 - a wrapped *Error is found and kept as is
+	notFound := NotFound("no chirp with that id", nil)
+	if got := From(fmt.Errorf("getChirp: %w", notFound)); got != notFound {
+		t.Fatalf("test-FAIL: expected the wrapped error back, got %v", got)
+	} else {
+		t.Logf("test-PASS: %v", got)
+	}
+
+	//test2 - anything else is a 500 that still unwraps to the cause
+	cause := errors.New("boom")
+	if got := From(cause); got.Status != 500 || got.Code != CodeInternal || !errors.Is(got, cause) {
+		t.Fatalf("test-FAIL: expected an internal error wrapping the cause, got %+v", got)
+	} else {
+		t.Logf("test-PASS: %v", got)
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	//test1 - a body over the limit is a 413
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("a", 20)))
+	_, err := http.MaxBytesReader(rec, req.Body, 10).Read(make([]byte, 20))
+	if got := ReadBody(err); got.Status != 413 || got.Code != CodeBodyTooLarge {
+		t.Fatalf("test-FAIL: expected 413 body_too_large, got %+v", got)
+	} else {
+		t.Logf("test-PASS: %s", got.Detail)
+	}
+
+	//test2 - any other read failure is a plain 400
+	if got := ReadBody(errors.New("connection reset")); got.Status != 400 || got.Code != CodeBadRequest {
+		t.Fatalf("test-FAIL: expected 400 bad_request, got %+v", got)
+	} else {
+		t.Logf("test-PASS: %s", got.Detail)
+	}
+}
+
+func TestCodeForStatus(t *testing.T) {
+	//test1 - common statuses get their generic code, unknown 4xx fall back to bad_request
+	cases := map[int]Code{400: CodeBadRequest, 401: CodeUnauthorized, 404: CodeNotFound, 412: CodePreconditionFailed, 418: CodeBadRequest, 429: CodeRateLimited, 503: CodeInternal}
+	for status, want := range cases {
+		if got := CodeForStatus(status); got != want {
+			t.Fatalf("test-FAIL: %d expected %s, got %s", status, want, got)
+		}
+	}
+	t.Logf("test-PASS: %d statuses mapped", len(cases))
+}
@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestChain(t *testing.T) {
+	createdAt := time.Date(2025, 5, 20, 12, 0, 0, 123456000, time.UTC)
+	events := []Event{
+		{CreatedAt: createdAt, Action: "login.succeeded", ActorID: uuid.NullUUID{UUID: uuid.New(), Valid: true}, IP: "10.0.0.1"},
+		{CreatedAt: createdAt.Add(time.Second), Action: "login.failed", Details: map[string]string{"reason": "bad_password"}},
+		{CreatedAt: createdAt.Add(2 * time.Second), Action: "chirp.deleted", TargetType: "chirp", TargetID: uuid.NewString()},
+	}
+	prevHashes := []string{}
+	hashes := []string{}
+	head := ""
+	for _, e := range events {
+		prevHashes = append(prevHashes, head)
+		head = Hash(head, e)
+		hashes = append(hashes, head)
+	}
+
+	//test1 - an untouched chain verifies
+	v := Verifier{}
+	for i, e := range events {
+		if err := v.Check(int64(i+1), prevHashes[i], hashes[i], e); err != nil {
+			t.Fatalf("test-FAIL: expected a clean chain, got %v", err)
+		}
+	}
+	if gotHead, count := v.Head(); gotHead != head || count != 3 {
+		t.Fatalf("test-FAIL: expected head %s after 3 events, got %s after %d", head, gotHead, count)
+	} else {
+		t.Logf("test-PASS: chain of %d verified", count)
+	}
+
+	//test2 - nil and empty details hash the same, the way jsonb hands them back
+	withNil := events[0]
+	withEmpty := events[0]
+	withEmpty.Details = map[string]string{}
+	if Hash("", withNil) != Hash("", withEmpty) {
+		t.Fatalf("test-FAIL: nil and empty details hashed differently")
+	} else {
+		t.Logf("test-PASS: nil and empty details match")
+	}
+
+	//test3 - a timestamp read back in another zone still hashes the same
+	moved := events[0]
+	moved.CreatedAt = createdAt.In(time.FixedZone("", 3600))
+	if Hash("", moved) != hashes[0] {
+		t.Fatalf("test-FAIL: time zone changed the hash")
+	} else {
+		t.Logf("test-PASS: hash is zone independent")
+	}
+
+	//test4 - editing an event is caught
+	edited := events[1]
+	edited.Details = map[string]string{"reason": "unknown_email"}
+	v = Verifier{}
+	v.Check(1, prevHashes[0], hashes[0], events[0])
+	if err := v.Check(2, prevHashes[1], hashes[1], edited); !errors.Is(err, ErrBadHash) {
+		t.Fatalf("test-FAIL: expected ErrBadHash, got %v", err)
+	} else {
+		t.Logf("test-PASS: %v", err)
+	}
+
+	//test5 - removing an event is caught on the one after it
+	v = Verifier{}
+	v.Check(1, prevHashes[0], hashes[0], events[0])
+	if err := v.Check(3, prevHashes[2], hashes[2], events[2]); !errors.Is(err, ErrBrokenLink) {
+		t.Fatalf("test-FAIL: expected ErrBrokenLink, got %v", err)
+	} else {
+		t.Logf("test-PASS: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWebhookSignatures(t *testing.T) {
+	body := []byte(`{"id":"evt_1","event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+	now := time.Now()
+
+	//test1 - request signed with the current secret
+	req1, _ := http.NewRequest("POST", "/api/polka/webhooks", nil)
+	SignWebhookRequest(req1, body, "current_secret", now)
+	sentBody, err := io.ReadAll(req1.Body)
+	if err != nil || string(sentBody) != string(body) {
+		t.Fatalf("test-FAIL: SignWebhookRequest did not set the request body")
+	}
+	if err := VerifyWebhookSignature(req1.Header, body, []string{"current_secret"}, 5*time.Minute, now); err != nil {
+		t.Fatalf("test-FAIL: VerifyWebhookSignature rejected a valid signature: %s", err)
+	} else {
+		t.Logf("test-PASS: VerifyWebhookSignature accepted a valid signature")
+	}
+
+	//test2 - signed with the old secret while rotating
+	req2, _ := http.NewRequest("POST", "/api/polka/webhooks", nil)
+	SignWebhookRequest(req2, body, "old_secret", now)
+	if err := VerifyWebhookSignature(req2.Header, body, []string{"current_secret", "old_secret"}, 5*time.Minute, now); err != nil {
+		t.Fatalf("test-FAIL: VerifyWebhookSignature rejected the previous secret during rotation: %s", err)
+	} else {
+		t.Logf("test-PASS: VerifyWebhookSignature accepted the previous secret")
+	}
+
+	//test3 - tampered body
+	if err := VerifyWebhookSignature(req1.Header, append(body, ' '), []string{"current_secret"}, 5*time.Minute, now); err != ErrWebhookBadSignature {
+		t.Fatalf("test-FAIL: VerifyWebhookSignature accepted a tampered body: %v", err)
+	} else {
+		t.Logf("test-PASS: VerifyWebhookSignature rejected a tampered body")
+	}
+
+	//test4 - replayed outside the tolerance window
+	req4, _ := http.NewRequest("POST", "/api/polka/webhooks", nil)
+	SignWebhookRequest(req4, body, "current_secret", now.Add(-10*time.Minute))
+	if err := VerifyWebhookSignature(req4.Header, body, []string{"current_secret"}, 5*time.Minute, now); err != ErrWebhookBadTimestamp {
+		t.Fatalf("test-FAIL: VerifyWebhookSignature accepted a stale timestamp: %v", err)
+	} else {
+		t.Logf("test-PASS: VerifyWebhookSignature rejected a stale timestamp")
+	}
+
+	//test5 - no headers at all
+	if err := VerifyWebhookSignature(http.Header{}, body, []string{"current_secret"}, 5*time.Minute, now); err != ErrWebhookMissingHeaders {
+		t.Fatalf("test-FAIL: VerifyWebhookSignature accepted a request with no signature: %v", err)
+	} else {
+		t.Logf("test-PASS: VerifyWebhookSignature rejected a request with no signature")
+	}
+}
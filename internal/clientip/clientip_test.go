@@ -0,0 +1,67 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrusted(t *testing.T) {
+	//test1 - CIDRs and single addresses, blanks skipped
+	trusted, err := ParseTrusted(" 10.0.0.0/8, 192.0.2.7,,::1 ")
+	if err != nil || len(trusted) != 3 || trusted[1].String() != "192.0.2.7/32" || trusted[2].String() != "::1/128" {
+		t.Fatalf("test-FAIL: unexpected prefixes %v %v", trusted, err)
+	} else {
+		t.Logf("test-PASS: %v", trusted)
+	}
+
+	//test2 - garbage is refused
+	for _, raw := range []string{"proxy", "10.0.0.0/33", "10.0.0/8"} {
+		if _, err := ParseTrusted(raw); err == nil {
+			t.Fatalf("test-FAIL: expected an error for %q", raw)
+		}
+	}
+	t.Logf("test-PASS: malformed entries refused")
+}
+
+func TestResolve(t *testing.T) {
+	trusted, _ := ParseTrusted("10.0.0.0/8")
+	resolve := func(remoteAddr string, forwardedFor ...string) string {
+		req := httptest.NewRequest("GET", "/api/chirps", nil)
+		req.RemoteAddr = remoteAddr
+		for _, header := range forwardedFor {
+			req.Header.Add("X-Forwarded-For", header)
+		}
+		return Resolve(req, trusted)
+	}
+
+	//test1 - an untrusted peer's X-Forwarded-For is ignored
+	if ip := resolve("203.0.113.5:4000", "198.51.100.1"); ip != "203.0.113.5" {
+		t.Fatalf("test-FAIL: expected the peer address, got %s", ip)
+	} else {
+		t.Logf("test-PASS: %s", ip)
+	}
+
+	//test2 - behind a trusted proxy the rightmost untrusted hop is the client, forged entries left of it don't count
+	if ip := resolve("10.0.0.2:4000", "1.2.3.4, 198.51.100.1", "10.0.0.3"); ip != "198.51.100.1" {
+		t.Fatalf("test-FAIL: expected the address the proxies were reached from, got %s", ip)
+	} else {
+		t.Logf("test-PASS: %s", ip)
+	}
+
+	//test3 - no header, or only trusted hops, falls back to the furthest address known
+	if ip := resolve("10.0.0.2:4000"); ip != "10.0.0.2" {
+		t.Fatalf("test-FAIL: expected the proxy itself without a header, got %s", ip)
+	}
+	if ip := resolve("10.0.0.2:4000", "10.0.0.9"); ip != "10.0.0.9" {
+		t.Fatalf("test-FAIL: expected the furthest trusted hop, got %s", ip)
+	} else {
+		t.Logf("test-PASS: %s", ip)
+	}
+
+	//test4 - a garbled hop stops the walk at the last readable one
+	if ip := resolve("10.0.0.2:4000", "198.51.100.1, unknown"); ip != "10.0.0.2" {
+		t.Fatalf("test-FAIL: expected the proxy when its hop is unreadable, got %s", ip)
+	} else {
+		t.Logf("test-PASS: %s", ip)
+	}
+}
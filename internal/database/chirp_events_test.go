@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSequenceChirpEvents(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	q := New(db)
+	first, second := uuid.New(), uuid.New()
+	t.Cleanup(func() { db.Exec("DELETE FROM chirp_events WHERE chirp_id IN ($1, $2)", first, second) })
+
+	insertEvent := func(tx *sql.Tx, chirpID uuid.UUID) {
+		t.Helper()
+		if _, err := tx.ExecContext(ctx, `INSERT INTO chirp_events (event_type, chirp_id, user_id, body, chirp_created_at, chirp_updated_at)
+			VALUES ('chirp.created', $1, $1, 'test', NOW(), NOW())`, chirpID); err != nil {
+			t.Fatalf("test-FAIL: inserting event: %v", err)
+		}
+	}
+	start, err := q.GetLatestChirpEventSeq(ctx)
+	if err != nil {
+		t.Fatalf("test-FAIL: %v", err)
+	}
+
+	//test1 - an event still in flight isn't numbered, the one committed after it is
+	early, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("test-FAIL: %v", err)
+	}
+	defer early.Rollback()
+	insertEvent(early, first)
+	late, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("test-FAIL: %v", err)
+	}
+	defer late.Rollback()
+	insertEvent(late, second)
+	if err := late.Commit(); err != nil {
+		t.Fatalf("test-FAIL: %v", err)
+	}
+	if _, err := q.SequenceChirpEvents(ctx); err != nil {
+		t.Fatalf("test-FAIL: sequencing: %v", err)
+	}
+	events, err := q.GetChirpEventsAfter(ctx, GetChirpEventsAfterParams{Seq: sql.NullInt64{Int64: start, Valid: true}, Limit: 100})
+	if err != nil || len(events) == 0 || events[len(events)-1].ChirpID != second {
+		t.Fatalf("test-FAIL: expected the committed event to be numbered, got %+v %v", events, err)
+	} else {
+		t.Logf("test-PASS: committed event got seq %d", events[len(events)-1].Seq.Int64)
+	}
+	afterSecond := events[len(events)-1].Seq.Int64
+
+	//test2 - the event that committed late, with the lower id, is numbered after it and not skipped
+	if err := early.Commit(); err != nil {
+		t.Fatalf("test-FAIL: %v", err)
+	}
+	if _, err := q.SequenceChirpEvents(ctx); err != nil {
+		t.Fatalf("test-FAIL: sequencing: %v", err)
+	}
+	events, err = q.GetChirpEventsAfter(ctx, GetChirpEventsAfterParams{Seq: sql.NullInt64{Int64: afterSecond, Valid: true}, Limit: 100})
+	if err != nil || len(events) == 0 || events[0].ChirpID != first {
+		t.Fatalf("test-FAIL: expected the late event after seq %d, got %+v %v", afterSecond, events, err)
+	} else {
+		t.Logf("test-PASS: late event got seq %d after %d", events[0].Seq.Int64, afterSecond)
+	}
+}
@@ -7,29 +7,74 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
+const countRecentDuplicateChirps = `-- name: CountRecentDuplicateChirps :one
+SELECT COUNT(*) FROM chirps
+WHERE md5(lower(body)) = md5(lower($1::text))
+AND created_at > NOW() - make_interval(mins => $2::int)
+`
+
+type CountRecentDuplicateChirpsParams struct {
+	Body          string
+	WindowMinutes int32
+}
+
+func (q *Queries) CountRecentDuplicateChirps(ctx context.Context, arg CountRecentDuplicateChirpsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countRecentDuplicateChirps, arg.Body, arg.WindowMinutes)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countUsersRecentChirps = `-- name: CountUsersRecentChirps :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND created_at > NOW() - make_interval(mins => $2::int)
+`
+
+type CountUsersRecentChirpsParams struct {
+	UserID        uuid.UUID
+	WindowMinutes int32
+}
+
+func (q *Queries) CountUsersRecentChirps(ctx context.Context, arg CountUsersRecentChirpsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsersRecentChirps, arg.UserID, arg.WindowMinutes)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id) 
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, shadow_limited_at, reply_to_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, shadow_limited_at, reply_to_id
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body            string
+	UserID          uuid.UUID
+	ShadowLimitedAt sql.NullTime
+	ReplyToID       uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.ShadowLimitedAt,
+		arg.ReplyToID,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,10 +82,31 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
+		&i.ShadowLimitedAt,
+		&i.ReplyToID,
 	)
 	return i, err
 }
 
+const deleteChirpIfUnchanged = `-- name: DeleteChirpIfUnchanged :execrows
+DELETE FROM chirps
+WHERE id = $1 AND ($2::timestamp IS NULL OR updated_at = $2)
+`
+
+type DeleteChirpIfUnchangedParams struct {
+	ID          uuid.UUID
+	IfUpdatedAt sql.NullTime
+}
+
+func (q *Queries) DeleteChirpIfUnchanged(ctx context.Context, arg DeleteChirpIfUnchangedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteChirpIfUnchanged, arg.ID, arg.IfUpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteOneChirp = `-- name: DeleteOneChirp :exec
 DELETE FROM chirps WHERE id = $1
 `
@@ -51,11 +117,17 @@ func (q *Queries) DeleteOneChirp(ctx context.Context, id uuid.UUID) error {
 }
 
 const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps ORDER BY created_at
+SELECT id, created_at, updated_at, body, user_id, hidden_at, shadow_limited_at, reply_to_id FROM chirps
+WHERE hidden_at IS NULL AND (shadow_limited_at IS NULL OR user_id = $1) AND NOT EXISTS (
+    SELECT 1 FROM user_blocks WHERE user_blocks.blocker_id = $1 AND user_blocks.blocked_id = chirps.user_id
+) AND NOT EXISTS (
+    SELECT 1 FROM user_mutes WHERE user_mutes.muter_id = $1 AND user_mutes.muted_id = chirps.user_id
+)
+ORDER BY created_at
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+func (q *Queries) GetAllChirps(ctx context.Context, viewerID uuid.NullUUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +141,9 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.HiddenAt,
+			&i.ShadowLimitedAt,
+			&i.ReplyToID,
 		); err != nil {
 			return nil, err
 		}
@@ -84,11 +159,22 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getAuthorsChirps = `-- name: GetAuthorsChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE user_id = $1 ORDER BY created_at
+SELECT id, created_at, updated_at, body, user_id, hidden_at, shadow_limited_at, reply_to_id FROM chirps
+WHERE user_id = $1 AND hidden_at IS NULL AND (shadow_limited_at IS NULL OR user_id = $2) AND NOT EXISTS (
+    SELECT 1 FROM user_blocks WHERE user_blocks.blocker_id = $2 AND user_blocks.blocked_id = chirps.user_id
+) AND NOT EXISTS (
+    SELECT 1 FROM user_mutes WHERE user_mutes.muter_id = $2 AND user_mutes.muted_id = chirps.user_id
+)
+ORDER BY created_at
 `
 
-func (q *Queries) GetAuthorsChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAuthorsChirps, userID)
+type GetAuthorsChirpsParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.NullUUID
+}
+
+func (q *Queries) GetAuthorsChirps(ctx context.Context, arg GetAuthorsChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAuthorsChirps, arg.UserID, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -102,6 +188,9 @@ func (q *Queries) GetAuthorsChirps(ctx context.Context, userID uuid.UUID) ([]Chi
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.HiddenAt,
+			&i.ShadowLimitedAt,
+			&i.ReplyToID,
 		); err != nil {
 			return nil, err
 		}
@@ -117,7 +206,7 @@ func (q *Queries) GetAuthorsChirps(ctx context.Context, userID uuid.UUID) ([]Chi
 }
 
 const getOneChirp = `-- name: GetOneChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, hidden_at, shadow_limited_at, reply_to_id FROM chirps WHERE id = $1
 `
 
 func (q *Queries) GetOneChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -129,6 +218,70 @@ func (q *Queries) GetOneChirp(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
+		&i.ShadowLimitedAt,
+		&i.ReplyToID,
+	)
+	return i, err
+}
+
+const hideChirp = `-- name: HideChirp :execrows
+UPDATE chirps SET hidden_at = NOW(), updated_at = NOW() WHERE id = $1 AND hidden_at IS NULL
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, hideChirp, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const lockUsersChirps = `-- name: LockUsersChirps :exec
+SELECT pg_advisory_xact_lock(hashtext('chirps:' || $1::uuid::text))
+`
+
+func (q *Queries) LockUsersChirps(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, lockUsersChirps, userID)
+	return err
+}
+
+const releaseShadowLimitedChirp = `-- name: ReleaseShadowLimitedChirp :execrows
+UPDATE chirps SET shadow_limited_at = NULL WHERE id = $1 AND shadow_limited_at IS NOT NULL
+`
+
+func (q *Queries) ReleaseShadowLimitedChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, releaseShadowLimitedChirp, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps SET body = $1, updated_at = NOW()
+WHERE id = $2 AND ($3::timestamp IS NULL OR updated_at = $3)
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, shadow_limited_at, reply_to_id
+`
+
+type UpdateChirpBodyParams struct {
+	Body        string
+	ID          uuid.UUID
+	IfUpdatedAt sql.NullTime
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.Body, arg.ID, arg.IfUpdatedAt)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.ShadowLimitedAt,
+		&i.ReplyToID,
 	)
 	return i, err
 }
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestUpsertDirectConversation(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	q := New(db)
+	directKey := sql.NullString{String: uuid.NewString() + ":" + uuid.NewString(), Valid: true}
+	t.Cleanup(func() { db.Exec("DELETE FROM conversations WHERE direct_key = $1", directKey) })
+
+	//test1 - a second upsert for the pair waits on the first one's insert, then gets its row back
+	first, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("test-FAIL: %v", err)
+	}
+	defer first.Rollback()
+	created, err := New(first).UpsertDirectConversation(ctx, UpsertDirectConversationParams{DirectKey: directKey})
+	if err != nil || !created.Created {
+		t.Fatalf("test-FAIL: expected the first upsert to create, got %+v %v", created, err)
+	}
+	type upsertResult struct {
+		row UpsertDirectConversationRow
+		err error
+	}
+	waiting := make(chan upsertResult)
+	go func() {
+		row, err := q.UpsertDirectConversation(ctx, UpsertDirectConversationParams{DirectKey: directKey})
+		waiting <- upsertResult{row, err}
+	}()
+	if err := first.Commit(); err != nil {
+		t.Fatalf("test-FAIL: %v", err)
+	}
+	reused := <-waiting
+	if reused.err != nil || reused.row.Created || reused.row.ID != created.ID {
+		t.Fatalf("test-FAIL: expected conversation %s reused, got %+v %v", created.ID, reused.row, reused.err)
+	} else {
+		t.Logf("test-PASS: one conversation %s for the pair", reused.row.ID)
+	}
+}
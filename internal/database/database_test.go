@@ -0,0 +1,28 @@
+package database
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+)
+
+// these tests run the queries against a real Postgres. point CHIRPY_TEST_DB_URL at a throwaway
+// database that goose has migrated up, without it they are skipped
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL is not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf("test-FAIL: opening test db: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatalf("test-FAIL: connecting to test db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
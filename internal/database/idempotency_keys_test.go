@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	q := New(db)
+	scope := "test:" + uuid.NewString()
+	t.Cleanup(func() { db.Exec("DELETE FROM idempotency_keys WHERE scope = $1", scope) })
+	claim := ClaimIdempotencyKeyParams{Scope: scope, Key: "k1", TtlSeconds: 60, RequestHash: "hash"}
+
+	//test1 - a new key is claimed, and its response saved in the same transaction
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("test-FAIL: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := q.WithTx(tx).ClaimIdempotencyKey(ctx, claim); err != nil {
+		t.Fatalf("test-FAIL: claiming a new key: %v", err)
+	}
+
+	//test2 - a concurrent claim of the same key waits for the first, then gets no row
+	secondClaim := make(chan error, 1)
+	go func() {
+		_, err := q.ClaimIdempotencyKey(ctx, claim)
+		secondClaim <- err
+	}()
+	select {
+	case err := <-secondClaim:
+		t.Fatalf("test-FAIL: expected the second claim to block, got %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	if err := q.WithTx(tx).SaveIdempotentResponse(ctx, SaveIdempotentResponseParams{
+		Scope:        scope,
+		Key:          "k1",
+		StatusCode:   sql.NullInt32{Int32: 201, Valid: true},
+		ContentType:  "application/json",
+		ResponseBody: []byte(`{"id":"1"}`),
+	}); err != nil {
+		t.Fatalf("test-FAIL: saving the response: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("test-FAIL: %v", err)
+	}
+	if err := <-secondClaim; !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("test-FAIL: expected no rows for the second claim, got %v", err)
+	} else {
+		t.Logf("test-PASS: concurrent claim waited and lost")
+	}
+
+	//test3 - the stored response can be read back for a replay
+	stored, err := q.GetIdempotencyKey(ctx, GetIdempotencyKeyParams{Scope: scope, Key: "k1"})
+	if err != nil || stored.StatusCode.Int32 != 201 || string(stored.ResponseBody) != `{"id":"1"}` || stored.RequestHash != "hash" {
+		t.Fatalf("test-FAIL: unexpected stored key %+v %v", stored, err)
+	} else {
+		t.Logf("test-PASS: stored %d %s", stored.StatusCode.Int32, stored.ResponseBody)
+	}
+
+	//test4 - an expired key can be claimed again and starts with no response, then gets pruned
+	expired := ClaimIdempotencyKeyParams{Scope: scope, Key: "k2", TtlSeconds: -1, RequestHash: "old"}
+	if _, err := q.ClaimIdempotencyKey(ctx, expired); err != nil {
+		t.Fatalf("test-FAIL: claiming k2: %v", err)
+	}
+	expired.RequestHash = "new"
+	reclaimed, err := q.ClaimIdempotencyKey(ctx, expired)
+	if err != nil || reclaimed.RequestHash != "new" || reclaimed.StatusCode.Valid {
+		t.Fatalf("test-FAIL: expected the expired key to be reclaimed, got %+v %v", reclaimed, err)
+	}
+	if _, err := q.DeleteExpiredIdempotencyKeys(ctx); err != nil {
+		t.Fatalf("test-FAIL: pruning: %v", err)
+	}
+	if _, err := q.GetIdempotencyKey(ctx, GetIdempotencyKeyParams{Scope: scope, Key: "k2"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("test-FAIL: expected k2 to be pruned, got %v", err)
+	}
+	if _, err := q.GetIdempotencyKey(ctx, GetIdempotencyKeyParams{Scope: scope, Key: "k1"}); err != nil {
+		t.Fatalf("test-FAIL: expected k1 to survive pruning, got %v", err)
+	} else {
+		t.Logf("test-PASS: expired key reclaimed and pruned")
+	}
+}
@@ -6,12 +6,88 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AuditEvent struct {
+	ID         int64
+	CreatedAt  time.Time
+	Action     string
+	ActorID    uuid.NullUUID
+	TargetType string
+	TargetID   string
+	Ip         string
+	UserAgent  string
+	Details    json.RawMessage
+	PrevHash   string
+	Hash       string
+}
+
+type Bookmark struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	FolderID  uuid.NullUUID
+	CreatedAt time.Time
+}
+
+type BookmarkFolder struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+}
+
 type Chirp struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Body            string
+	UserID          uuid.UUID
+	HiddenAt        sql.NullTime
+	ShadowLimitedAt sql.NullTime
+	ReplyToID       uuid.NullUUID
+}
+
+type ChirpEvent struct {
+	ID             int64
+	CreatedAt      time.Time
+	EventType      string
+	ChirpID        uuid.UUID
+	UserID         uuid.UUID
+	Body           string
+	ChirpCreatedAt time.Time
+	ChirpUpdatedAt time.Time
+	Seq            sql.NullInt64
+	ReplyToID      uuid.NullUUID
+}
+
+type ChirpLike struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Conversation struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	CreatedBy     uuid.NullUUID
+	LastMessageAt sql.NullTime
+	DirectKey     sql.NullString
+}
+
+type ConversationParticipant struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	LastReadAt     sql.NullTime
+}
+
+type Draft struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -19,6 +95,104 @@ type Chirp struct {
 	UserID    uuid.UUID
 }
 
+type FilterRule struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Pattern   string
+	Locale    string
+	Actions   []string
+	CreatedBy uuid.NullUUID
+}
+
+type IdempotencyKey struct {
+	Scope        string
+	Key          string
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+	RequestHash  string
+	StatusCode   sql.NullInt32
+	ContentType  string
+	ResponseBody []byte
+}
+
+type Message struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+}
+
+type ModerationAction struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	ModeratorID  uuid.NullUUID
+	ReportID     uuid.NullUUID
+	Action       string
+	ChirpID      uuid.NullUUID
+	TargetUserID uuid.NullUUID
+	Notes        string
+}
+
+type Notification struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Type       string
+	GroupKey   string
+	ChirpID    uuid.NullUUID
+	ActorIds   []uuid.UUID
+	EventCount int32
+	ReadAt     sql.NullTime
+}
+
+type NotificationMute struct {
+	UserID    uuid.UUID
+	Type      string
+	CreatedAt time.Time
+}
+
+type Poll struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	ChirpID        uuid.UUID
+	AllowsMultiple bool
+	ExpiresAt      time.Time
+}
+
+type PollOption struct {
+	ID       uuid.UUID
+	PollID   uuid.UUID
+	Position int32
+	Text     string
+}
+
+type PollVote struct {
+	PollID    uuid.UUID
+	OptionID  uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type PolkaEvent struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Event     string
+	UserID    uuid.NullUUID
+	Result    string
+	Payload   json.RawMessage
+	EventID   sql.NullString
+}
+
+type RateLimitBucket struct {
+	Key       string
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
 type RefreshToken struct {
 	Token     string
 	CreatedAt time.Time
@@ -28,6 +202,75 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type Report struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	ReporterID   uuid.NullUUID
+	ChirpID      uuid.NullUUID
+	ChirpBody    sql.NullString
+	TargetUserID uuid.NullUUID
+	Reason       string
+	Details      string
+	Status       string
+	ClaimedBy    uuid.NullUUID
+	ClaimedAt    sql.NullTime
+	ResolvedBy   uuid.NullUUID
+	ResolvedAt   sql.NullTime
+	Resolution   sql.NullString
+}
+
+type ScheduledChirp struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	PublishAt time.Time
+	Locale    string
+}
+
+type SpamDecision struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UserID        uuid.UUID
+	ChirpID       uuid.NullUUID
+	Body          string
+	Score         float64
+	Decision      string
+	Features      json.RawMessage
+	ReviewedBy    uuid.NullUUID
+	ReviewedAt    sql.NullTime
+	FalsePositive sql.NullBool
+}
+
+type SpamSetting struct {
+	ID                     bool
+	UpdatedAt              time.Time
+	UpdatedBy              uuid.NullUUID
+	ShadowLimitScore       float64
+	RejectScore            float64
+	DuplicateWindowMinutes int32
+	DuplicateWeight        float64
+	LinkWeight             float64
+	NewAccountHours        int32
+	NewAccountWeight       float64
+	VelocityWindowMinutes  int32
+	VelocityLimit          int32
+	VelocityWeight         float64
+}
+
+type Subscription struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	UserID           uuid.UUID
+	Plan             string
+	Status           string
+	CurrentPeriodEnd time.Time
+	Source           string
+}
+
 type User struct {
 	ID          uuid.UUID
 	CreatedAt   time.Time
@@ -35,4 +278,51 @@ type User struct {
 	Email       string
 	PwHash      string
 	IsChirpyRed bool
+	SuspendedAt sql.NullTime
+	Role        string
+	Handle      sql.NullString
+}
+
+type UserBlock struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type UserFollow struct {
+	FollowerID uuid.UUID
+	FollowedID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type UserMute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	SubscriptionID uuid.UUID
+	EventType      string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookSubscription struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.NullUUID
+	Url       string
+	Secret    string
+	Events    []string
+	Active    bool
 }
@@ -56,11 +56,23 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (uu
 	return user_id, err
 }
 
-const revokeRefreshTokenFromDB = `-- name: RevokeRefreshTokenFromDB :exec
+const revokeRefreshTokenFromDB = `-- name: RevokeRefreshTokenFromDB :one
 UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW() WHERE token = $1
+RETURNING user_id
 `
 
-func (q *Queries) RevokeRefreshTokenFromDB(ctx context.Context, token string) error {
-	_, err := q.db.ExecContext(ctx, revokeRefreshTokenFromDB, token)
+func (q *Queries) RevokeRefreshTokenFromDB(ctx context.Context, token string) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, revokeRefreshTokenFromDB, token)
+	var user_id uuid.UUID
+	err := row.Scan(&user_id)
+	return user_id, err
+}
+
+const revokeUsersRefreshTokens = `-- name: RevokeUsersRefreshTokens :exec
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeUsersRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeUsersRefreshTokens, userID)
 	return err
 }
@@ -7,8 +7,10 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
@@ -20,7 +22,7 @@ VALUES (
     $1,
     $2
 )
-RETURNING id, created_at, updated_at, email, pw_hash, is_chirpy_red
+RETURNING id, created_at, updated_at, email, pw_hash, is_chirpy_red, suspended_at, role, handle
 `
 
 type CreateUserParams struct {
@@ -38,12 +40,74 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.PwHash,
 		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.Role,
+		&i.Handle,
 	)
 	return i, err
 }
 
+const getUser = `-- name: GetUser :one
+SELECT id, created_at, updated_at, email, pw_hash, is_chirpy_red, suspended_at, role, handle FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.PwHash,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.Role,
+		&i.Handle,
+	)
+	return i, err
+}
+
+const getUserAccountAgeHours = `-- name: GetUserAccountAgeHours :one
+SELECT (EXTRACT(EPOCH FROM NOW() - created_at) / 3600)::float8 FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserAccountAgeHours(ctx context.Context, id uuid.UUID) (float64, error) {
+	row := q.db.QueryRowContext(ctx, getUserAccountAgeHours, id)
+	var column_1 float64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const getUserIDsByHandles = `-- name: GetUserIDsByHandles :many
+SELECT id FROM users WHERE handle = ANY($1::text[])
+`
+
+func (q *Queries) GetUserIDsByHandles(ctx context.Context, handles []string) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getUserIDsByHandles, pq.Array(handles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const lookupUser = `-- name: LookupUser :one
-SELECT id, created_at, updated_at, email, pw_hash, is_chirpy_red FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, pw_hash, is_chirpy_red, suspended_at, role, handle FROM users WHERE email = $1
 `
 
 func (q *Queries) LookupUser(ctx context.Context, email string) (User, error) {
@@ -56,6 +120,9 @@ func (q *Queries) LookupUser(ctx context.Context, email string) (User, error) {
 		&i.Email,
 		&i.PwHash,
 		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.Role,
+		&i.Handle,
 	)
 	return i, err
 }
@@ -69,9 +136,102 @@ func (q *Queries) ResetUsers(ctx context.Context) error {
 	return err
 }
 
+const setUserHandle = `-- name: SetUserHandle :one
+UPDATE users SET handle = $1, updated_at = NOW() WHERE id = $2
+RETURNING id, created_at, updated_at, email, pw_hash, is_chirpy_red, suspended_at, role, handle
+`
+
+type SetUserHandleParams struct {
+	Handle sql.NullString
+	ID     uuid.UUID
+}
+
+func (q *Queries) SetUserHandle(ctx context.Context, arg SetUserHandleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserHandle, arg.Handle, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.PwHash,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.Role,
+		&i.Handle,
+	)
+	return i, err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2
+RETURNING id, created_at, updated_at, email, pw_hash, is_chirpy_red, suspended_at, role, handle
+`
+
+type SetUserRoleParams struct {
+	Role string
+	ID   uuid.UUID
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.Role, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.PwHash,
+		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.Role,
+		&i.Handle,
+	)
+	return i, err
+}
+
+const suspendUser = `-- name: SuspendUser :execrows
+UPDATE users SET suspended_at = NOW(), updated_at = NOW() WHERE id = $1 AND suspended_at IS NULL
+`
+
+func (q *Queries) SuspendUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, suspendUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const syncUserChirpyRed = `-- name: SyncUserChirpyRed :exec
+UPDATE users SET is_chirpy_red = EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE subscriptions.user_id = users.id
+    AND subscriptions.status IN ('active', 'past_due')
+    AND subscriptions.current_period_end > NOW()
+), updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) SyncUserChirpyRed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, syncUserChirpyRed, id)
+	return err
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :execrows
+UPDATE users SET suspended_at = NULL, updated_at = NOW() WHERE id = $1 AND suspended_at IS NOT NULL
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unsuspendUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users SET email = $1, pw_hash = $2, updated_at = NOW() WHERE id = $3
-RETURNING  id, created_at, updated_at, email, pw_hash, is_chirpy_red
+RETURNING  id, created_at, updated_at, email, pw_hash, is_chirpy_red, suspended_at, role, handle
 `
 
 type UpdateUserParams struct {
@@ -90,15 +250,9 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.PwHash,
 		&i.IsChirpyRed,
+		&i.SuspendedAt,
+		&i.Role,
+		&i.Handle,
 	)
 	return i, err
 }
-
-const upgradeUserToRed = `-- name: UpgradeUserToRed :exec
-UPDATE users SET is_chirpy_red = true, updated_at = NOW() WHERE id = $1
-`
-
-func (q *Queries) UpgradeUserToRed(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeUserToRed, id)
-	return err
-}
@@ -0,0 +1,120 @@
+package dbmetrics
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"time"
+)
+
+// what QueryName returns for statements that didn't come from sqlc
+const UnnamedQuery = "other"
+
+// called once per statement run on a wrapped connection, with the context the statement ran
+// under. duration is the time until the driver came back, for queries that's before any rows are read
+type Observer func(ctx context.Context, query string, duration time.Duration, err error)
+
+// sqlc starts every query with "-- name: GetUser :one", this pulls out the GetUser
+func QueryName(query string) string {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(query), "-- name: ")
+	if !ok {
+		return UnnamedQuery
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	if name == "" {
+		return UnnamedQuery
+	}
+	return name
+}
+
+// wraps a connector so every Exec and Query on its connections is reported to observe.
+// it sits under database/sql, so statements run inside transactions are seen too
+func NewConnector(base driver.Connector, observe Observer) driver.Connector {
+	return &connector{base: base, observe: observe}
+}
+
+type connector struct {
+	base    driver.Connector
+	observe Observer
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.base.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &observedConn{Conn: conn, observe: c.observe}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.base.Driver()
+}
+
+// the wrapped driver's optional interfaces are passed through when it has them
+type observedConn struct {
+	driver.Conn
+	observe Observer
+}
+
+func (c *observedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if !errors.Is(err, driver.ErrSkip) {
+		c.observe(ctx, query, time.Since(start), err)
+	}
+	return rows, err
+}
+
+func (c *observedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	if !errors.Is(err, driver.ErrSkip) {
+		c.observe(ctx, query, time.Since(start), err)
+	}
+	return result, err
+}
+
+func (c *observedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *observedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	//same fallback database/sql uses for drivers without BeginTx
+	return c.Conn.Begin()
+}
+
+func (c *observedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *observedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *observedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package dbmetrics
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// just enough of a driver to run Exec and Query through database/sql
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+func (fakeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (fakeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return fakeRows{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string         { return []string{"n"} }
+func (fakeRows) Close() error              { return nil }
+func (fakeRows) Next([]driver.Value) error { return io.EOF }
+
+func TestQueryName(t *testing.T) {
+	//test1 - sqlc's header comment gives the name
+	if name := QueryName("-- name: GetUser :one\nSELECT * FROM users WHERE id = $1"); name != "GetUser" {
+		t.Fatalf("test-FAIL: expected GetUser, got %s", name)
+	} else {
+		t.Logf("test-PASS: got %s", name)
+	}
+
+	//test2 - anything else is lumped together
+	if name := QueryName("SELECT 1"); name != UnnamedQuery {
+		t.Fatalf("test-FAIL: expected %s, got %s", UnnamedQuery, name)
+	} else {
+		t.Logf("test-PASS: got %s", name)
+	}
+}
+
+func TestConnector(t *testing.T) {
+	observed := []string{}
+	db := sql.OpenDB(NewConnector(fakeConnector{}, func(_ context.Context, query string, _ time.Duration, _ error) {
+		observed = append(observed, QueryName(query))
+	}))
+	defer db.Close()
+
+	//test1 - statements on the pool are observed
+	if _, err := db.ExecContext(context.Background(), "-- name: ResetUsers :exec\nDELETE FROM users"); err != nil {
+		t.Fatalf("test-FAIL: exec failed: %v", err)
+	}
+	rows, err := db.QueryContext(context.Background(), "-- name: GetUsers :many\nSELECT 1")
+	if err != nil {
+		t.Fatalf("test-FAIL: query failed: %v", err)
+	}
+	rows.Close()
+	if len(observed) != 2 || observed[0] != "ResetUsers" || observed[1] != "GetUsers" {
+		t.Fatalf("test-FAIL: expected ResetUsers and GetUsers, got %v", observed)
+	} else {
+		t.Logf("test-PASS: observed %v", observed)
+	}
+
+	//test2 - so are statements inside a transaction
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("test-FAIL: begin failed: %v", err)
+	}
+	if _, err := tx.ExecContext(context.Background(), "-- name: SuspendUser :execrows\nUPDATE users"); err != nil {
+		t.Fatalf("test-FAIL: exec in tx failed: %v", err)
+	}
+	tx.Commit()
+	if len(observed) != 3 || observed[2] != "SuspendUser" {
+		t.Fatalf("test-FAIL: expected SuspendUser from the transaction, got %v", observed)
+	} else {
+		t.Logf("test-PASS: transaction statement observed")
+	}
+}
@@ -0,0 +1,80 @@
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// a strong ETag over parts. anything that changes the response has to be one of them
+func ETag(parts ...string) string {
+	hash := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+// ETag and Last-Modified, sent on 200s and 304s alike. a zero lastModified is left out
+func SetValidators(header http.Header, etag string, lastModified time.Time) {
+	header.Set("ETag", etag)
+	if !lastModified.IsZero() {
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// whether a GET can be answered with 304. If-None-Match wins when both are sent,
+// If-Modified-Since is only looked at without it (RFC 9110 13.2.2)
+func NotModified(req *http.Request, etag string, lastModified time.Time) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if ifNoneMatch := req.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return matchesAny(ifNoneMatch, etag, false)
+	}
+	ifModifiedSince := req.Header.Get("If-Modified-Since")
+	if ifModifiedSince == "" || lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	//the header only has whole seconds
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// whether an edit has to be refused with 412 because If-Match doesn't name the current etag.
+// no If-Match means the client didn't ask for a check. the resource exists, so * always matches
+func PreconditionFailed(req *http.Request, etag string) bool {
+	ifMatch := req.Header.Get("If-Match")
+	if ifMatch == "" {
+		return false
+	}
+	return !matchesAny(ifMatch, etag, true)
+}
+
+// answers a conditional GET. the validators and Cache-Control should already be set
+func WriteNotModified(res http.ResponseWriter) {
+	res.Header().Del("Content-Type")
+	res.WriteHeader(http.StatusNotModified)
+}
+
+// If-Match compares strongly, so weak tags never match it. If-None-Match compares weakly
+func matchesAny(list, etag string, strong bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if strong {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
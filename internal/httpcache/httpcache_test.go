@@ -0,0 +1,112 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestETag(t *testing.T) {
+	//test1 - same parts, same quoted tag. different parts, different tag
+	first := ETag("chirp", "1", "2025-01-01T00:00:00Z")
+	if first != ETag("chirp", "1", "2025-01-01T00:00:00Z") || first[0] != '"' || first[len(first)-1] != '"' {
+		t.Fatalf("test-FAIL: expected a stable quoted tag, got %s", first)
+	}
+	if first == ETag("chirp", "12025-01-01T00:00:00Z") || first == ETag("chirp", "1", "2025-01-01T00:00:01Z") {
+		t.Fatalf("test-FAIL: expected different parts to give different tags")
+	} else {
+		t.Logf("test-PASS: %s", first)
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	etag := ETag("v1")
+	lastModified := time.Date(2025, 1, 1, 12, 0, 0, 500, time.UTC)
+	request := func(method string, headers map[string]string) *http.Request {
+		req := httptest.NewRequest(method, "/api/chirps", nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		return req
+	}
+
+	//test1 - If-None-Match matches the current tag, weak or strong, alone or in a list
+	for _, ifNoneMatch := range []string{etag, "W/" + etag, `"old", ` + etag, "*"} {
+		if !NotModified(request("GET", map[string]string{"If-None-Match": ifNoneMatch}), etag, lastModified) {
+			t.Fatalf("test-FAIL: expected %s to match", ifNoneMatch)
+		}
+	}
+	t.Logf("test-PASS: If-None-Match matched")
+
+	//test2 - a stale tag wins over a fresh If-Modified-Since
+	req := request("GET", map[string]string{"If-None-Match": `"old"`, "If-Modified-Since": "Wed, 01 Jan 2025 13:00:00 GMT"})
+	if NotModified(req, etag, lastModified) {
+		t.Fatalf("test-FAIL: expected If-None-Match to take precedence")
+	} else {
+		t.Logf("test-PASS: If-None-Match took precedence")
+	}
+
+	//test3 - If-Modified-Since at or after Last-Modified, to the second
+	if !NotModified(request("GET", map[string]string{"If-Modified-Since": "Wed, 01 Jan 2025 12:00:00 GMT"}), etag, lastModified) {
+		t.Fatalf("test-FAIL: expected not modified since the same second")
+	}
+	if NotModified(request("GET", map[string]string{"If-Modified-Since": "Wed, 01 Jan 2025 11:59:59 GMT"}), etag, lastModified) {
+		t.Fatalf("test-FAIL: expected modified since an earlier second")
+	} else {
+		t.Logf("test-PASS: If-Modified-Since compared to the second")
+	}
+
+	//test4 - no conditions, a garbage date, or a write never gets a 304
+	for _, req := range []*http.Request{
+		request("GET", nil),
+		request("GET", map[string]string{"If-Modified-Since": "yesterday"}),
+		request("POST", map[string]string{"If-None-Match": etag}),
+	} {
+		if NotModified(req, etag, lastModified) {
+			t.Fatalf("test-FAIL: expected a full response for %s %v", req.Method, req.Header)
+		}
+	}
+	t.Logf("test-PASS: unconditional requests get a full response")
+}
+
+func TestPreconditionFailed(t *testing.T) {
+	etag := ETag("v2")
+	request := func(ifMatch string) *http.Request {
+		req := httptest.NewRequest("PUT", "/api/chirps/1", nil)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		return req
+	}
+
+	//test1 - no If-Match, the current tag or * go ahead
+	for _, ifMatch := range []string{"", etag, `"v1", ` + etag, "*"} {
+		if PreconditionFailed(request(ifMatch), etag) {
+			t.Fatalf("test-FAIL: expected %q to pass", ifMatch)
+		}
+	}
+	t.Logf("test-PASS: matching preconditions pass")
+
+	//test2 - an old tag, or a weak copy of the current one, is refused
+	for _, ifMatch := range []string{ETag("v1"), "W/" + etag} {
+		if !PreconditionFailed(request(ifMatch), etag) {
+			t.Fatalf("test-FAIL: expected %q to fail", ifMatch)
+		}
+	}
+	t.Logf("test-PASS: stale and weak tags refused")
+}
+
+func TestWriteNotModified(t *testing.T) {
+	//test1 - a 304 keeps the validators and drops the content type
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Type", "application/json")
+	SetValidators(rec.Header(), ETag("v1"), time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	WriteNotModified(rec)
+	if rec.Code != 304 || rec.Header().Get("Content-Type") != "" || rec.Header().Get("ETag") == "" ||
+		rec.Header().Get("Last-Modified") != "Wed, 01 Jan 2025 12:00:00 GMT" {
+		t.Fatalf("test-FAIL: unexpected 304 %d %v", rec.Code, rec.Header())
+	} else {
+		t.Logf("test-PASS: %v", rec.Header())
+	}
+}
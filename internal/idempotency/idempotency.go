@@ -0,0 +1,73 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+)
+
+const (
+	Header = "Idempotency-Key"
+	// set on responses that were replayed instead of handled again
+	ReplayedHeader = "Idempotent-Replayed"
+	maxKeyLength   = 255
+)
+
+// keys are opaque to us, but have to fit in a header and in the log
+func ValidKey(key string) bool {
+	if key == "" || len(key) > maxKeyLength {
+		return false
+	}
+	for _, r := range key {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// what a key gets tied to the first time it's used. a retry has to match it to be replayed
+func Fingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + "\n" + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// passes the response through to the client and keeps a copy to store under the key
+type Recorder struct {
+	http.ResponseWriter
+	Status int
+	Body   bytes.Buffer
+}
+
+func NewRecorder(res http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: res, Status: http.StatusOK}
+}
+
+func (r *Recorder) WriteHeader(status int) {
+	r.Status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	r.Body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// writes a stored response back out as it was first sent
+func Replay(res http.ResponseWriter, status int, contentType string, body []byte) {
+	if contentType != "" {
+		res.Header().Set("Content-Type", contentType)
+	}
+	res.Header().Set(ReplayedHeader, "true")
+	res.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	res.WriteHeader(status)
+	res.Write(body)
+}
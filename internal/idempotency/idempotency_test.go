@@ -0,0 +1,76 @@
+package idempotency
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidKey(t *testing.T) {
+	//test1 - uuids and other printable keys are fine
+	for _, key := range []string{"0b9a6f2e-7c1d-4f55-9d8e-2a4b6c8d0e1f", "retry-42", strings.Repeat("k", 255)} {
+		if !ValidKey(key) {
+			t.Fatalf("test-FAIL: expected %q to be valid", key)
+		}
+	}
+	t.Logf("test-PASS: valid keys accepted")
+
+	//test2 - empty, oversized, spaces and control characters are refused
+	for _, key := range []string{"", strings.Repeat("k", 256), "has space", "tab\tkey", "ключ"} {
+		if ValidKey(key) {
+			t.Fatalf("test-FAIL: expected %q to be refused", key)
+		}
+	}
+	t.Logf("test-PASS: bad keys refused")
+}
+
+func TestFingerprint(t *testing.T) {
+	body := []byte(`{"body":"hello"}`)
+	first := Fingerprint("POST", "/api/chirps", body)
+
+	//test1 - the same request gives the same fingerprint
+	if Fingerprint("POST", "/api/chirps", []byte(`{"body":"hello"}`)) != first {
+		t.Fatalf("test-FAIL: expected a stable fingerprint")
+	} else {
+		t.Logf("test-PASS: %s", first)
+	}
+
+	//test2 - a different body, path or method changes it
+	others := []string{
+		Fingerprint("POST", "/api/chirps", []byte(`{"body":"hello!"}`)),
+		Fingerprint("POST", "/api/drafts", body),
+		Fingerprint("PUT", "/api/chirps", body),
+		Fingerprint("POST", "/api/chirps\n", []byte(`{"body":"hello"}`)[1:]),
+	}
+	for _, other := range others {
+		if other == first {
+			t.Fatalf("test-FAIL: expected a different fingerprint")
+		}
+	}
+	t.Logf("test-PASS: %d variations differ", len(others))
+}
+
+func TestRecorderAndReplay(t *testing.T) {
+	//test1 - the recorder passes the response through and keeps a copy
+	rec := httptest.NewRecorder()
+	recorder := NewRecorder(rec)
+	recorder.Header().Set("Content-Type", "application/json")
+	recorder.WriteHeader(201)
+	recorder.Write([]byte(`{"id":"1"}`))
+	if rec.Code != 201 || rec.Body.String() != `{"id":"1"}` || recorder.Status != 201 || recorder.Body.String() != `{"id":"1"}` {
+		t.Fatalf("test-FAIL: expected the response both sent and recorded, got %d %s / %d %s",
+			rec.Code, rec.Body.String(), recorder.Status, recorder.Body.String())
+	} else {
+		t.Logf("test-PASS: recorded %d %s", recorder.Status, recorder.Body.String())
+	}
+
+	//test2 - a replay sends the stored response back, marked as replayed
+	replayed := httptest.NewRecorder()
+	Replay(replayed, recorder.Status, rec.Header().Get("Content-Type"), recorder.Body.Bytes())
+	if replayed.Code != 201 || replayed.Body.String() != `{"id":"1"}` ||
+		replayed.Header().Get("Content-Type") != "application/json" || replayed.Header().Get(ReplayedHeader) != "true" {
+		t.Fatalf("test-FAIL: unexpected replay %d %v %s", replayed.Code, replayed.Header(), replayed.Body.String())
+	} else {
+		t.Logf("test-PASS: replayed %d %s", replayed.Code, replayed.Body.String())
+	}
+}
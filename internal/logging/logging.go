@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	//longer ids from clients are replaced rather than trusted
+	maxRequestIDLength = 128
+)
+
+type loggerKey struct{}
+
+// returns ctx carrying logger, pulled back out with FromContext
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// the logger the request middleware attached, already tagged with the request id.
+// falls back to slog.Default() for background work
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// 16 random bytes, hex encoded
+func NewRequestID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// keeps the caller's id when it looks like one, so a request can be followed across services.
+// anything empty, too long or outside [A-Za-z0-9._-] gets a fresh id instead
+func RequestID(incoming string) string {
+	if incoming == "" || len(incoming) > maxRequestIDLength {
+		return NewRequestID()
+	}
+	for _, c := range incoming {
+		isAlnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlnum && c != '-' && c != '_' && c != '.' {
+			return NewRequestID()
+		}
+	}
+	return incoming
+}
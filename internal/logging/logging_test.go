@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	//test1 - a sane incoming id is kept
+	if id := RequestID("req-123_abc.4"); id != "req-123_abc.4" {
+		t.Fatalf("test-FAIL: expected the incoming id back, got %s", id)
+	} else {
+		t.Logf("test-PASS: kept %s", id)
+	}
+
+	//test2 - missing, oversized or odd ids are replaced
+	for _, incoming := range []string{"", strings.Repeat("a", 129), "bad id", "line\nbreak"} {
+		if id := RequestID(incoming); id == incoming || len(id) != 32 {
+			t.Fatalf("test-FAIL: expected a fresh id for %q, got %q", incoming, id)
+		}
+	}
+	t.Logf("test-PASS: bad ids replaced")
+}
+
+func TestFromContext(t *testing.T) {
+	//test1 - no logger on the context gives the default
+	if FromContext(context.Background()) != slog.Default() {
+		t.Fatalf("test-FAIL: expected slog.Default()")
+	} else {
+		t.Logf("test-PASS: default logger")
+	}
+
+	//test2 - an attached logger comes back with its attributes
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil)).With("request_id", "abc")
+	FromContext(WithLogger(context.Background(), logger)).Info("hello")
+	if !strings.Contains(buf.String(), `"request_id":"abc"`) {
+		t.Fatalf("test-FAIL: expected request_id in %s", buf.String())
+	} else {
+		t.Logf("test-PASS: %s", strings.TrimSpace(buf.String()))
+	}
+}
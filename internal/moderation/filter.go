@@ -0,0 +1,193 @@
+package moderation
+
+import (
+	"slices"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// what happens to a chirp that matches a rule. a rule can carry more than one
+type Action string
+
+const (
+	ActionMask   Action = "mask"
+	ActionReject Action = "reject"
+	ActionFlag   Action = "flag"
+)
+
+var Actions = []Action{ActionMask, ActionReject, ActionFlag}
+
+const maskString = "****"
+
+// Locale "" applies everywhere, anything else only to chirps written in that locale
+type Rule struct {
+	ID      uuid.UUID
+	Pattern string
+	Locale  string
+	Actions []Action
+}
+
+type Match struct {
+	Rule Rule
+	//byte offsets into the checked text
+	Start int
+	End   int
+}
+
+type Result struct {
+	//the checked text with every mask match replaced
+	Text    string
+	Matches []Match
+	Reject  bool
+	Flag    bool
+}
+
+// Filter is an immutable compiled rule set. build a new one when the rules change
+type Filter struct {
+	rules []Rule
+	//one matcher per locale, each holding the global rules plus that locale's own
+	matchers map[string]*matcher
+	//rule index for each matcher pattern, per locale
+	ruleIndexes map[string][]int
+}
+
+func NewFilter(rules []Rule) *Filter {
+	f := &Filter{
+		rules:       rules,
+		matchers:    map[string]*matcher{},
+		ruleIndexes: map[string][]int{},
+	}
+	locales := []string{""}
+	for _, rule := range rules {
+		if locale := normalizeLocale(rule.Locale); !slices.Contains(locales, locale) {
+			locales = append(locales, locale)
+		}
+	}
+	for _, locale := range locales {
+		patterns := [][]rune{}
+		indexes := []int{}
+		for i, rule := range rules {
+			ruleLocale := normalizeLocale(rule.Locale)
+			if ruleLocale != "" && ruleLocale != locale {
+				continue
+			}
+			pattern := []rune(Normalize(strings.TrimSpace(rule.Pattern)))
+			if len(pattern) == 0 {
+				continue
+			}
+			patterns = append(patterns, pattern)
+			indexes = append(indexes, i)
+		}
+		f.matchers[locale] = newMatcher(patterns)
+		f.ruleIndexes[locale] = indexes
+	}
+	return f
+}
+
+// "en-US" and "EN_us" both mean "en"
+func normalizeLocale(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+	return locale
+}
+
+// Check runs text through the global rules and the rules for locale. only whole words count,
+// judged on the original text so "fornax!" matches but "fornaxes" doesn't
+func (f *Filter) Check(text, locale string) Result {
+	result := Result{Text: text, Matches: []Match{}}
+	locale = normalizeLocale(locale)
+	m, ok := f.matchers[locale]
+	if !ok {
+		locale = ""
+		m = f.matchers[locale]
+	}
+
+	normalized := normalizeWithOffsets(text)
+	masks := [][2]int{}
+	for _, hit := range m.findAll(normalized.runes) {
+		start, end := normalized.starts[hit.start], normalized.ends[hit.end-1]
+		if !isWordBoundary(text, start, end) {
+			continue
+		}
+		rule := f.rules[f.ruleIndexes[locale][hit.pattern]]
+		result.Matches = append(result.Matches, Match{Rule: rule, Start: start, End: end})
+		for _, action := range rule.Actions {
+			switch action {
+			case ActionMask:
+				masks = append(masks, [2]int{start, end})
+			case ActionReject:
+				result.Reject = true
+			case ActionFlag:
+				result.Flag = true
+			}
+		}
+	}
+	result.Text = applyMasks(text, masks)
+	return result
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isWordBoundary(text string, start, end int) bool {
+	if start > 0 {
+		if before, _ := utf8.DecodeLastRuneInString(text[:start]); isWordRune(before) {
+			return false
+		}
+	}
+	if end < len(text) {
+		if after, _ := utf8.DecodeRuneInString(text[end:]); isWordRune(after) {
+			return false
+		}
+	}
+	return true
+}
+
+// overlapping masks merge into one so a span is only ever masked once
+func applyMasks(text string, masks [][2]int) string {
+	if len(masks) == 0 {
+		return text
+	}
+	slices.SortFunc(masks, func(a, b [2]int) int { return a[0] - b[0] })
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(masks); i++ {
+		start, end := masks[i][0], masks[i][1]
+		for i+1 < len(masks) && masks[i+1][0] < end {
+			i++
+			end = max(end, masks[i][1])
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(maskString)
+		last = end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// Engine holds the live Filter. Check is safe to call while Load swaps in a rebuilt one
+type Engine struct {
+	current atomic.Pointer[Filter]
+}
+
+func NewEngine(rules []Rule) *Engine {
+	e := &Engine{}
+	e.Load(rules)
+	return e
+}
+
+// Load compiles rules and replaces the live filter with them
+func (e *Engine) Load(rules []Rule) {
+	e.current.Store(NewFilter(rules))
+}
+
+func (e *Engine) Check(text, locale string) Result {
+	return e.current.Load().Check(text, locale)
+}
@@ -0,0 +1,95 @@
+package moderation
+
+import "testing"
+
+func TestFilter(t *testing.T) {
+	filter := NewFilter([]Rule{
+		{Pattern: "kerfuffle", Actions: []Action{ActionMask}},
+		{Pattern: "sharbert", Actions: []Action{ActionMask}},
+		{Pattern: "fornax", Actions: []Action{ActionMask, ActionFlag}},
+		{Pattern: "spamlink", Actions: []Action{ActionReject}},
+		{Pattern: "schnitzel", Locale: "de", Actions: []Action{ActionMask}},
+	})
+
+	//test1 - the old regex behaviour, whole words only and case insensitive
+	result := filter.Check("This is a Kerfuffle opinion, not kerfuffles!", "")
+	if result.Text != "This is a **** opinion, not kerfuffles!" {
+		t.Fatalf("test-FAIL: expected only the whole word masked, got %q", result.Text)
+	} else {
+		t.Logf("test-PASS: whole word masked: %q", result.Text)
+	}
+
+	//test2 - punctuation next to a word is still a boundary
+	result = filter.Check("what a sharbert!", "")
+	if result.Text != "what a ****!" {
+		t.Fatalf("test-FAIL: expected word before punctuation masked, got %q", result.Text)
+	} else {
+		t.Logf("test-PASS: word before punctuation masked: %q", result.Text)
+	}
+
+	//test3 - leetspeak, accents, fullwidth and zero width evasions all get caught
+	for _, evasion := range []string{"k3rfuffl3", "kérfüffle", "ｋｅｒｆｕｆｆｌｅ", "ker\u200bfuffle", "ke\u0301rfuffle", "$harb3rt"} {
+		result = filter.Check("oh "+evasion+" ok", "")
+		if result.Text != "oh **** ok" {
+			t.Fatalf("test-FAIL: evasion %q not masked, got %q", evasion, result.Text)
+		}
+	}
+	t.Logf("test-PASS: evasions masked")
+
+	//test4 - actions combine across matches
+	result = filter.Check("fornax", "")
+	if result.Text != "****" || !result.Flag || result.Reject || len(result.Matches) != 1 {
+		t.Fatalf("test-FAIL: expected a masked and flagged match, got %+v", result)
+	} else {
+		t.Logf("test-PASS: mask and flag both applied")
+	}
+	result = filter.Check("buy at spamlink now", "")
+	if !result.Reject || result.Text != "buy at spamlink now" {
+		t.Fatalf("test-FAIL: expected a rejected but unmasked result, got %+v", result)
+	} else {
+		t.Logf("test-PASS: reject rule rejected without masking")
+	}
+
+	//test5 - locale rules only apply to their locale
+	if result = filter.Check("schnitzel", "en"); result.Text != "schnitzel" {
+		t.Fatalf("test-FAIL: de rule applied to en chirp: %q", result.Text)
+	}
+	if result = filter.Check("schnitzel", "de-AT"); result.Text != "****" {
+		t.Fatalf("test-FAIL: de rule not applied to de-AT chirp: %q", result.Text)
+	}
+	t.Logf("test-PASS: locale rules scoped to their locale")
+
+	//test6 - clean text comes back untouched
+	if result = filter.Check("nothing to see here", ""); result.Text != "nothing to see here" || len(result.Matches) != 0 {
+		t.Fatalf("test-FAIL: clean text changed: %+v", result)
+	} else {
+		t.Logf("test-PASS: clean text untouched")
+	}
+}
+
+func TestMatcherOverlaps(t *testing.T) {
+	m := newMatcher([][]rune{[]rune("he"), []rune("she"), []rune("his"), []rune("hers")})
+
+	//test1 - the textbook aho-corasick example, every overlapping hit is found
+	hits := m.findAll([]rune("ushers"))
+	if len(hits) != 3 {
+		t.Fatalf("test-FAIL: expected she, he and hers in ushers, got %+v", hits)
+	} else {
+		t.Logf("test-PASS: found %d overlapping hits", len(hits))
+	}
+}
+
+func TestEngineLoad(t *testing.T) {
+	engine := NewEngine(nil)
+
+	//test1 - rules loaded later take effect straight away
+	if result := engine.Check("fornax", ""); result.Text != "fornax" {
+		t.Fatalf("test-FAIL: empty engine changed text: %q", result.Text)
+	}
+	engine.Load([]Rule{{Pattern: "fornax", Actions: []Action{ActionMask}}})
+	if result := engine.Check("fornax", ""); result.Text != "****" {
+		t.Fatalf("test-FAIL: reloaded rule not applied: %q", result.Text)
+	} else {
+		t.Logf("test-PASS: reloaded rules applied")
+	}
+}
@@ -0,0 +1,92 @@
+package moderation
+
+// aho-corasick automaton over normalized runes. built once per rule set and then only read,
+// so one matcher can be shared by every request
+
+type matcherNode struct {
+	children map[rune]int
+	fail     int
+	//indexes into matcher.patterns that end at this node, including ones reached through fail links
+	outputs []int
+}
+
+type matcher struct {
+	nodes    []matcherNode
+	patterns [][]rune
+}
+
+// a hit for patterns[pattern] covering runes [start, end) of the searched text
+type matcherHit struct {
+	pattern int
+	start   int
+	end     int
+}
+
+func newMatcher(patterns [][]rune) *matcher {
+	m := &matcher{
+		nodes:    []matcherNode{{children: map[rune]int{}}},
+		patterns: patterns,
+	}
+	for i, pattern := range patterns {
+		if len(pattern) == 0 {
+			continue
+		}
+		node := 0
+		for _, r := range pattern {
+			next, ok := m.nodes[node].children[r]
+			if !ok {
+				next = len(m.nodes)
+				m.nodes = append(m.nodes, matcherNode{children: map[rune]int{}})
+				m.nodes[node].children[r] = next
+			}
+			node = next
+		}
+		m.nodes[node].outputs = append(m.nodes[node].outputs, i)
+	}
+
+	//breadth first so a node's fail link is always finished before its children need it
+	queue := []int{}
+	for _, child := range m.nodes[0].children {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[node].children {
+			fail := m.nodes[node].fail
+			for fail != 0 {
+				if _, ok := m.nodes[fail].children[r]; ok {
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			if next, ok := m.nodes[fail].children[r]; ok && next != child {
+				m.nodes[child].fail = next
+			}
+			m.nodes[child].outputs = append(m.nodes[child].outputs, m.nodes[m.nodes[child].fail].outputs...)
+			queue = append(queue, child)
+		}
+	}
+	return m
+}
+
+// every occurrence of every pattern in text, overlapping ones included
+func (m *matcher) findAll(text []rune) []matcherHit {
+	hits := []matcherHit{}
+	node := 0
+	for i, r := range text {
+		for node != 0 {
+			if _, ok := m.nodes[node].children[r]; ok {
+				break
+			}
+			node = m.nodes[node].fail
+		}
+		if next, ok := m.nodes[node].children[r]; ok {
+			node = next
+		}
+		for _, pattern := range m.nodes[node].outputs {
+			hits = append(hits, matcherHit{pattern: pattern, start: i + 1 - len(m.patterns[pattern]), end: i + 1})
+		}
+	}
+	return hits
+}
@@ -0,0 +1,140 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// accented and look-alike letters folded to the plain latin letter they're standing in for.
+// covers latin-1, latin extended-a and the cyrillic/greek letters that look like latin ones
+var foldGroups = map[rune]string{
+	'a': "àáâãäåāăąаαά",
+	'c': "çćĉċčсς",
+	'd': "ďđ",
+	'e': "èéêëēĕėęěеεέё",
+	'g': "ĝğġģ",
+	'h': "ĥħһ",
+	'i': "ìíîïĩīĭįıіїιί",
+	'j': "ĵј",
+	'k': "ķкκ",
+	'l': "ĺļľŀł",
+	'n': "ñńņňŉη",
+	'o': "òóôõöøōŏőоοό",
+	'p': "рρ",
+	'r': "ŕŗř",
+	's': "śŝşšѕ",
+	't': "ţťŧτ",
+	'u': "ùúûüũūŭůűųυ",
+	'v': "ν",
+	'w': "ŵ",
+	'x': "хχ",
+	'y': "ýÿŷуγ",
+	'z': "źżž",
+}
+
+// leetspeak substitutions. l, 1, | and ! all fold to i so "he11" and "hell" look the same to the matcher
+var leetFolds = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'!': 'i',
+	'|': 'i',
+	'l': 'i',
+	'3': 'e',
+	'4': 'a',
+	'@': 'a',
+	'5': 's',
+	'$': 's',
+	'7': 't',
+	'8': 'b',
+	'9': 'g',
+}
+
+// letters that fold to more than one
+var expandFolds = map[rune]string{
+	'ß': "ss",
+	'æ': "ae",
+	'œ': "oe",
+	'þ': "th",
+}
+
+var runeFolds = map[rune]rune{}
+
+func init() {
+	for base, variants := range foldGroups {
+		for _, variant := range variants {
+			runeFolds[variant] = base
+		}
+	}
+}
+
+// zero width characters people slip into words to break up a match
+func isInvisible(r rune) bool {
+	switch r {
+	case '\u00ad', '\u200b', '\u200c', '\u200d', '\u2060', '\ufeff':
+		return true
+	}
+	return false
+}
+
+// foldRune lowercases r and undoes the usual evasions. an empty result means drop the rune
+func foldRune(r rune) string {
+	if isInvisible(r) || unicode.Is(unicode.Mn, r) {
+		return ""
+	}
+	//fullwidth ascii
+	if r >= '\uff01' && r <= '\uff5e' {
+		r -= 0xfee0
+	}
+	r = unicode.ToLower(r)
+	if expanded, ok := expandFolds[r]; ok {
+		return expanded
+	}
+	if folded, ok := runeFolds[r]; ok {
+		r = folded
+	}
+	if folded, ok := leetFolds[r]; ok {
+		r = folded
+	}
+	return string(r)
+}
+
+// Normalize folds s the same way chirps are folded before matching. rule patterns go through it too
+func Normalize(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		b.WriteString(foldRune(r))
+	}
+	return b.String()
+}
+
+// normalized text plus, for every normalized rune, the byte span of the original rune it came from
+type normalizedText struct {
+	runes  []rune
+	starts []int
+	ends   []int
+}
+
+func normalizeWithOffsets(s string) normalizedText {
+	text := normalizedText{}
+	for i, r := range s {
+		width := utf8.RuneLen(r)
+		if width < 0 {
+			width = 1
+		}
+		folded := foldRune(r)
+		if folded == "" {
+			//a dropped combining mark still belongs to the letter before it
+			if n := len(text.ends); n > 0 && text.ends[n-1] == i {
+				text.ends[n-1] = i + width
+			}
+			continue
+		}
+		for _, foldedRune := range folded {
+			text.runes = append(text.runes, foldedRune)
+			text.starts = append(text.starts, i)
+			text.ends = append(text.ends, i+width)
+		}
+	}
+	return text
+}
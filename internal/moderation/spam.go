@@ -0,0 +1,75 @@
+package moderation
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// what happens to a chirp after spam scoring
+type SpamDecision string
+
+const (
+	SpamAccept      SpamDecision = "accept"
+	SpamShadowLimit SpamDecision = "shadow_limit"
+	SpamReject      SpamDecision = "reject"
+)
+
+// thresholds and weights, loaded from the DB on every check so they can change without a deploy
+type SpamSettings struct {
+	ShadowLimitScore float64
+	RejectScore      float64
+	DuplicateWeight  float64
+	LinkWeight       float64
+	NewAccountAge    time.Duration
+	NewAccountWeight float64
+	VelocityLimit    int
+	VelocityWeight   float64
+}
+
+// everything the score is built from, stored alongside the decision for review
+type SpamFeatures struct {
+	//identical chirps (any author) in the duplicate window
+	DuplicateCount int `json:"duplicate_count"`
+	LinkCount      int `json:"link_count"`
+	WordCount      int `json:"word_count"`
+	//links per word, 0 to 1
+	LinkDensity     float64 `json:"link_density"`
+	AccountAgeHours float64 `json:"account_age_hours"`
+	//the author's own chirps in the velocity window
+	RecentChirps int     `json:"recent_chirps"`
+	Score        float64 `json:"score"`
+}
+
+var linkRegex = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+|\b[a-z0-9-]+\.(?:com|net|org|io|ly|co|xyz|info|biz)\b`)
+
+// fills in the link counts from the chirp body
+func (f *SpamFeatures) CountLinks(body string) {
+	f.WordCount = len(strings.Fields(body))
+	f.LinkCount = len(linkRegex.FindAllString(body, -1))
+	if f.WordCount > 0 {
+		f.LinkDensity = min(float64(f.LinkCount)/float64(f.WordCount), 1)
+	}
+}
+
+// ScoreSpam adds up the weighted features and sets f.Score. each duplicate and each chirp over the
+// velocity limit adds its weight again, link density scales the link weight, new accounts get a flat bump
+func ScoreSpam(f *SpamFeatures, settings SpamSettings) SpamDecision {
+	score := float64(f.DuplicateCount) * settings.DuplicateWeight
+	score += f.LinkDensity * settings.LinkWeight
+	if f.AccountAgeHours < settings.NewAccountAge.Hours() {
+		score += settings.NewAccountWeight
+	}
+	if f.RecentChirps > settings.VelocityLimit {
+		score += float64(f.RecentChirps-settings.VelocityLimit) * settings.VelocityWeight
+	}
+	f.Score = score
+
+	switch {
+	case score >= settings.RejectScore:
+		return SpamReject
+	case score >= settings.ShadowLimitScore:
+		return SpamShadowLimit
+	}
+	return SpamAccept
+}
@@ -0,0 +1,55 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScoreSpam(t *testing.T) {
+	settings := SpamSettings{
+		ShadowLimitScore: 5,
+		RejectScore:      10,
+		DuplicateWeight:  3,
+		LinkWeight:       4,
+		NewAccountAge:    24 * time.Hour,
+		NewAccountWeight: 2,
+		VelocityLimit:    10,
+		VelocityWeight:   1,
+	}
+
+	//test1 - an ordinary chirp from an established account is accepted
+	features := SpamFeatures{AccountAgeHours: 500, RecentChirps: 2}
+	features.CountLinks("just had a great sandwich")
+	if decision := ScoreSpam(&features, settings); decision != SpamAccept || features.Score != 0 {
+		t.Fatalf("test-FAIL: expected accept with score 0, got %s %v", decision, features.Score)
+	} else {
+		t.Logf("test-PASS: ordinary chirp accepted")
+	}
+
+	//test2 - links are counted per word
+	features = SpamFeatures{AccountAgeHours: 500}
+	features.CountLinks("buy now https://spam.example/x cheap.com")
+	if features.LinkCount != 2 || features.WordCount != 4 || features.LinkDensity != 0.5 {
+		t.Fatalf("test-FAIL: expected 2 links in 4 words, got %+v", features)
+	} else {
+		t.Logf("test-PASS: link density %v", features.LinkDensity)
+	}
+
+	//test3 - a new account repeating itself gets shadow limited
+	features = SpamFeatures{AccountAgeHours: 1, DuplicateCount: 1}
+	features.CountLinks("follow me")
+	if decision := ScoreSpam(&features, settings); decision != SpamShadowLimit || features.Score != 5 {
+		t.Fatalf("test-FAIL: expected shadow_limit with score 5, got %s %v", decision, features.Score)
+	} else {
+		t.Logf("test-PASS: new duplicate poster shadow limited")
+	}
+
+	//test4 - enough signals together get rejected
+	features = SpamFeatures{AccountAgeHours: 1, DuplicateCount: 2, RecentChirps: 12}
+	features.CountLinks("https://spam.example")
+	if decision := ScoreSpam(&features, settings); decision != SpamReject {
+		t.Fatalf("test-FAIL: expected reject, got %s %v", decision, features.Score)
+	} else {
+		t.Logf("test-PASS: rejected with score %v", features.Score)
+	}
+}
@@ -0,0 +1,106 @@
+package pubsub
+
+import "sync"
+
+// Broker fans published values out to every subscriber in this process.
+// Publish never blocks: a subscriber whose buffer is full gets dropped and its channel closed,
+// so one slow reader can't hold up everyone else
+type Broker[T any] struct {
+	mu          sync.Mutex
+	bufferSize  int
+	subscribers map[chan T]struct{}
+}
+
+func NewBroker[T any](bufferSize int) *Broker[T] {
+	return &Broker[T]{
+		bufferSize:  bufferSize,
+		subscribers: map[chan T]struct{}{},
+	}
+}
+
+func (b *Broker[T]) Subscribe() chan T {
+	ch := make(chan T, b.bufferSize)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+// safe to call on a channel the broker already dropped
+func (b *Broker[T]) Unsubscribe(ch chan T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (b *Broker[T]) Publish(value T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- value:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Topics is a Broker per key, for values only one audience should see (a user's own notifications).
+// subscribers only get what's published to their key, with the same drop-when-full rule
+type Topics[K comparable, T any] struct {
+	mu          sync.Mutex
+	bufferSize  int
+	subscribers map[K]map[chan T]struct{}
+}
+
+func NewTopics[K comparable, T any](bufferSize int) *Topics[K, T] {
+	return &Topics[K, T]{
+		bufferSize:  bufferSize,
+		subscribers: map[K]map[chan T]struct{}{},
+	}
+}
+
+func (t *Topics[K, T]) Subscribe(key K) chan T {
+	ch := make(chan T, t.bufferSize)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.subscribers[key] == nil {
+		t.subscribers[key] = map[chan T]struct{}{}
+	}
+	t.subscribers[key][ch] = struct{}{}
+	return ch
+}
+
+// safe to call on a channel that was already dropped
+func (t *Topics[K, T]) Unsubscribe(key K, ch chan T) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.subscribers[key][ch]; ok {
+		t.drop(key, ch)
+	}
+}
+
+func (t *Topics[K, T]) Publish(key K, value T) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for ch := range t.subscribers[key] {
+		select {
+		case ch <- value:
+		default:
+			t.drop(key, ch)
+		}
+	}
+}
+
+// callers hold mu
+func (t *Topics[K, T]) drop(key K, ch chan T) {
+	delete(t.subscribers[key], ch)
+	if len(t.subscribers[key]) == 0 {
+		delete(t.subscribers, key)
+	}
+	close(ch)
+}
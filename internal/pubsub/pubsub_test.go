@@ -0,0 +1,95 @@
+package pubsub
+
+import "testing"
+
+func TestBroker(t *testing.T) {
+	broker := NewBroker[int](2)
+	fast := broker.Subscribe()
+	slow := broker.Subscribe()
+
+	//test1 - every subscriber gets a published value
+	broker.Publish(1)
+	if <-fast != 1 || <-slow != 1 {
+		t.Fatalf("test-FAIL: subscribers did not receive the published value")
+	} else {
+		t.Logf("test-PASS: both subscribers received the published value")
+	}
+
+	//test2 - a subscriber that stops reading gets dropped once its buffer is full
+	for i := 2; i <= 4; i++ {
+		broker.Publish(i)
+		<-fast
+	}
+	received := []int{}
+	for value := range slow {
+		received = append(received, value)
+	}
+	if len(received) != 2 {
+		t.Fatalf("test-FAIL: slow subscriber should have been closed after 2 buffered values, got %v", received)
+	} else {
+		t.Logf("test-PASS: slow subscriber was dropped after filling its buffer")
+	}
+
+	//test3 - unsubscribing a dropped channel is a no-op, the fast one keeps working
+	broker.Unsubscribe(slow)
+	broker.Publish(5)
+	if <-fast != 5 {
+		t.Fatalf("test-FAIL: fast subscriber stopped receiving after the slow one was dropped")
+	} else {
+		t.Logf("test-PASS: fast subscriber still receives values")
+	}
+
+	//test4 - unsubscribe closes the channel
+	broker.Unsubscribe(fast)
+	if _, ok := <-fast; ok {
+		t.Fatalf("test-FAIL: Unsubscribe did not close the channel")
+	} else {
+		t.Logf("test-PASS: Unsubscribe closed the channel")
+	}
+}
+
+func TestTopics(t *testing.T) {
+	topics := NewTopics[string, int](1)
+	alice := topics.Subscribe("alice")
+	bob := topics.Subscribe("bob")
+
+	//test1 - a value only goes to its own key
+	topics.Publish("alice", 1)
+	if <-alice != 1 || len(bob) != 0 {
+		t.Fatalf("test-FAIL: value published to alice reached bob or didn't reach alice")
+	} else {
+		t.Logf("test-PASS: only alice received her value")
+	}
+
+	//test2 - other keys can't fill a subscriber's buffer
+	for i := 0; i < 5; i++ {
+		topics.Publish("alice", i)
+		<-alice
+	}
+	topics.Publish("bob", 2)
+	if <-bob != 2 {
+		t.Fatalf("test-FAIL: bob was dropped by traffic on alice's key")
+	} else {
+		t.Logf("test-PASS: bob is unaffected by alice's traffic")
+	}
+
+	//test3 - a full subscriber is dropped and unsubscribing it afterwards is a no-op
+	topics.Publish("bob", 3)
+	topics.Publish("bob", 4)
+	if value, ok := <-bob; !ok || value != 3 {
+		t.Fatalf("test-FAIL: expected the buffered 3 before the close, got %v %v", value, ok)
+	}
+	if _, ok := <-bob; ok {
+		t.Fatalf("test-FAIL: full subscriber wasn't closed")
+	}
+	topics.Unsubscribe("bob", bob)
+	t.Logf("test-PASS: full subscriber was dropped and closed")
+
+	//test4 - unsubscribe closes the channel
+	topics.Unsubscribe("alice", alice)
+	if _, ok := <-alice; ok {
+		t.Fatalf("test-FAIL: Unsubscribe did not close the channel")
+	} else {
+		t.Logf("test-PASS: Unsubscribe closed the channel")
+	}
+}
@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+)
+
+// keeps buckets in rate_limit_buckets so every instance shares them. the refill math
+// runs in take_rate_limit_token on the database clock, instance clocks don't matter
+type PostgresStore struct {
+	db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	row, err := s.db.TakeRateLimitToken(ctx, database.TakeRateLimitTokenParams{
+		Key:             key,
+		Capacity:        float64(limit.Burst),
+		RefillPerSecond: limit.perSecond(),
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(limit, row.Tokens, row.Allowed), nil
+}
+
+func (s *PostgresStore) Prune(ctx context.Context, idle time.Duration) error {
+	_, err := s.db.DeleteIdleRateLimitBuckets(ctx, idle.Seconds())
+	return err
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+// runs take_rate_limit_token on a real Postgres. CHIRPY_TEST_DB_URL has to point at a throwaway
+// database goose has migrated up, without it the test is skipped
+func TestPostgresStore(t *testing.T) {
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL is not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf("test-FAIL: opening test db: %v", err)
+	}
+	defer db.Close()
+	prefix := "test:" + uuid.NewString() + ":"
+	defer db.Exec("DELETE FROM rate_limit_buckets WHERE key LIKE $1", prefix+"%")
+	store := NewPostgresStore(database.New(db))
+	ctx := context.Background()
+
+	//test1 - the burst goes through, the next request is refused
+	limit := Limit{Burst: 3, Period: time.Hour}
+	for i := 0; i < 3; i++ {
+		result, err := store.Take(ctx, prefix+"burst", limit)
+		if err != nil || !result.Allowed || result.Remaining != 2-i {
+			t.Fatalf("test-FAIL: request %d expected allowed with %d left, got %+v %v", i, 2-i, result, err)
+		}
+	}
+	if result, err := store.Take(ctx, prefix+"burst", limit); err != nil || result.Allowed || result.RetryAfter <= 0 {
+		t.Fatalf("test-FAIL: expected a refusal after the burst, got %+v %v", result, err)
+	} else {
+		t.Logf("test-PASS: refused after the burst, %+v", result)
+	}
+
+	//test2 - concurrent takes on one key queue on the row lock, none of them double spend
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := store.Take(ctx, prefix+"concurrent", Limit{Burst: 5, Period: time.Hour})
+			if err != nil {
+				t.Errorf("test-FAIL: concurrent take: %v", err)
+				return
+			}
+			if result.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 5 {
+		t.Fatalf("test-FAIL: expected exactly the burst of 5 allowed, got %d", allowed)
+	} else {
+		t.Logf("test-PASS: %d of 10 concurrent requests allowed", allowed)
+	}
+
+	//test3 - tokens come back on the database clock
+	refilling := Limit{Burst: 1, Period: 200 * time.Millisecond}
+	store.Take(ctx, prefix+"refill", refilling)
+	if result, _ := store.Take(ctx, prefix+"refill", refilling); result.Allowed {
+		t.Fatalf("test-FAIL: expected the empty bucket to refuse, got %+v", result)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if result, err := store.Take(ctx, prefix+"refill", refilling); err != nil || !result.Allowed {
+		t.Fatalf("test-FAIL: expected a refilled token, got %+v %v", result, err)
+	} else {
+		t.Logf("test-PASS: refilled, %+v", result)
+	}
+
+	//test4 - prune keeps buckets used within the idle window
+	if err := store.Prune(ctx, time.Hour); err != nil {
+		t.Fatalf("test-FAIL: prune: %v", err)
+	}
+	var kept int
+	if err := db.QueryRow("SELECT COUNT(*) FROM rate_limit_buckets WHERE key LIKE $1", prefix+"%").Scan(&kept); err != nil || kept != 3 {
+		t.Fatalf("test-FAIL: expected the 3 recent buckets kept, got %d %v", kept, err)
+	} else {
+		t.Logf("test-PASS: %d recent buckets kept", kept)
+	}
+}
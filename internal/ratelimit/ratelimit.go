@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// a token bucket. Burst requests can go through at once, and the bucket refills
+// at Burst tokens per Period
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// reads limits written as "<burst>/<period>", like "300/1m"
+func ParseLimit(raw string) (Limit, error) {
+	rawBurst, rawPeriod, ok := strings.Cut(strings.TrimSpace(raw), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("ratelimit/ratelimit.go: limit %q should look like 300/1m", raw)
+	}
+	burst, err := strconv.Atoi(rawBurst)
+	if err != nil || burst < 1 {
+		return Limit{}, fmt.Errorf("ratelimit/ratelimit.go: limit %q needs a positive request count", raw)
+	}
+	period, err := time.ParseDuration(rawPeriod)
+	if err != nil || period <= 0 {
+		return Limit{}, fmt.Errorf("ratelimit/ratelimit.go: limit %q needs a positive period", raw)
+	}
+	return Limit{Burst: burst, Period: period}, nil
+}
+
+// the same limit with multiplier times the burst and refill rate
+func (l Limit) Scale(multiplier int) Limit {
+	if multiplier <= 1 {
+		return l
+	}
+	return Limit{Burst: l.Burst * multiplier, Period: l.Period}
+}
+
+func (l Limit) perSecond() float64 {
+	return float64(l.Burst) / l.Period.Seconds()
+}
+
+// the outcome of taking a token
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// until the bucket is full again
+	Reset time.Duration
+	// until the next token, zero when the request was allowed
+	RetryAfter time.Duration
+}
+
+// builds a Result from what is left in the bucket after the take
+func newResult(limit Limit, tokens float64, allowed bool) Result {
+	rate := limit.perSecond()
+	result := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: max(int(math.Floor(tokens)), 0),
+		Reset:     time.Duration((float64(limit.Burst) - tokens) / rate * float64(time.Second)),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	return result
+}
+
+// where the buckets live. every key gets its own bucket, created full on first use
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// forgets buckets untouched for longer than idle. pass at least the longest Period in use,
+	// by then a bucket is full again and dropping it changes nothing
+	Prune(ctx context.Context, idle time.Duration) error
+}
+
+// adds the RateLimit-* headers from the IETF ratelimit headers draft, plus Retry-After when refused.
+// times are whole seconds, rounded up so clients never retry early
+func SetHeaders(header http.Header, limit Limit, result Result) {
+	header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", result.Limit, ceilSeconds(limit.Period)))
+	if !result.Allowed {
+		header.Set("Retry-After", strconv.Itoa(max(ceilSeconds(result.RetryAfter), 1)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
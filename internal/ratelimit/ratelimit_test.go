@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// a memory store on a clock the test moves by hand
+func newTestStore() (*MemoryStore, *time.Time) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	return store, &now
+}
+
+func TestParseLimit(t *testing.T) {
+	//test1 - burst and period are read
+	limit, err := ParseLimit("300/1m")
+	if err != nil || limit.Burst != 300 || limit.Period != time.Minute {
+		t.Fatalf("test-FAIL: expected 300/1m, got %+v %v", limit, err)
+	} else {
+		t.Logf("test-PASS: %+v", limit)
+	}
+
+	//test2 - malformed limits are refused
+	for _, raw := range []string{"", "300", "0/1m", "-1/1m", "abc/1m", "300/soon", "300/0s"} {
+		if _, err := ParseLimit(raw); err == nil {
+			t.Fatalf("test-FAIL: expected an error for %q", raw)
+		}
+	}
+	t.Logf("test-PASS: malformed limits refused")
+}
+
+func TestMemoryStore(t *testing.T) {
+	store, now := newTestStore()
+	ctx := context.Background()
+	limit := Limit{Burst: 3, Period: 3 * time.Second}
+
+	//test1 - the burst goes through, the next request is refused
+	for i := 0; i < 3; i++ {
+		result, _ := store.Take(ctx, "ip:1.2.3.4", limit)
+		if !result.Allowed || result.Remaining != 2-i {
+			t.Fatalf("test-FAIL: request %d expected allowed with %d left, got %+v", i, 2-i, result)
+		}
+	}
+	result, _ := store.Take(ctx, "ip:1.2.3.4", limit)
+	if result.Allowed || result.RetryAfter != time.Second || result.Reset != 3*time.Second {
+		t.Fatalf("test-FAIL: expected a refusal with a 1s retry, got %+v", result)
+	} else {
+		t.Logf("test-PASS: refused after the burst, %+v", result)
+	}
+
+	//test2 - other keys have their own bucket
+	if result, _ := store.Take(ctx, "ip:5.6.7.8", limit); !result.Allowed {
+		t.Fatalf("test-FAIL: expected a fresh bucket for another key, got %+v", result)
+	} else {
+		t.Logf("test-PASS: separate bucket per key")
+	}
+
+	//test3 - tokens come back over time, but never past the burst
+	*now = now.Add(time.Second)
+	if result, _ := store.Take(ctx, "ip:1.2.3.4", limit); !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("test-FAIL: expected one refilled token, got %+v", result)
+	}
+	*now = now.Add(time.Hour)
+	if result, _ := store.Take(ctx, "ip:1.2.3.4", limit); !result.Allowed || result.Remaining != 2 {
+		t.Fatalf("test-FAIL: expected a full bucket capped at the burst, got %+v", result)
+	} else {
+		t.Logf("test-PASS: refilled to %d", result.Remaining+1)
+	}
+
+	//test4 - prune drops idle buckets only
+	*now = now.Add(10 * time.Second)
+	store.Take(ctx, "user:active", limit)
+	store.Prune(ctx, 5*time.Second)
+	if _, ok := store.buckets["ip:1.2.3.4"]; ok || len(store.buckets) != 1 {
+		t.Fatalf("test-FAIL: expected only the active bucket left, got %d", len(store.buckets))
+	} else {
+		t.Logf("test-PASS: idle buckets pruned")
+	}
+}
+
+func TestScale(t *testing.T) {
+	//test1 - a scaled limit allows more at once and refills faster
+	store, now := newTestStore()
+	ctx := context.Background()
+	limit := Limit{Burst: 2, Period: 2 * time.Second}.Scale(5)
+	if limit.Burst != 10 || limit.Period != 2*time.Second {
+		t.Fatalf("test-FAIL: expected 10/2s, got %+v", limit)
+	}
+	for i := 0; i < 10; i++ {
+		store.Take(ctx, "user:red", limit)
+	}
+	*now = now.Add(time.Second)
+	if result, _ := store.Take(ctx, "user:red", limit); !result.Allowed || result.Remaining != 4 {
+		t.Fatalf("test-FAIL: expected 5 tokens back after a second, got %+v", result)
+	} else {
+		t.Logf("test-PASS: %+v", result)
+	}
+
+	//test2 - a multiplier of 1 or less leaves the limit alone
+	if base := (Limit{Burst: 2, Period: time.Second}); base.Scale(0) != base || base.Scale(1) != base {
+		t.Fatalf("test-FAIL: expected the limit unchanged")
+	} else {
+		t.Logf("test-PASS: unscaled")
+	}
+}
+
+func TestSetHeaders(t *testing.T) {
+	limit := Limit{Burst: 30, Period: time.Minute}
+
+	//test1 - allowed requests get the RateLimit headers and no Retry-After
+	header := http.Header{}
+	SetHeaders(header, limit, newResult(limit, 12.5, true))
+	if header.Get("RateLimit-Limit") != "30" || header.Get("RateLimit-Remaining") != "12" ||
+		header.Get("RateLimit-Reset") != "35" || header.Get("RateLimit-Policy") != "30;w=60" || header.Get("Retry-After") != "" {
+		t.Fatalf("test-FAIL: unexpected headers %v", header)
+	} else {
+		t.Logf("test-PASS: %v", header)
+	}
+
+	//test2 - a refusal says when to come back, rounded up
+	header = http.Header{}
+	SetHeaders(header, limit, newResult(limit, 0.9, false))
+	if header.Get("Retry-After") != "1" || header.Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("test-FAIL: unexpected headers %v", header)
+	} else {
+		t.Logf("test-PASS: Retry-After %s", header.Get("Retry-After"))
+	}
+}
@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// starts a server span per request, continuing the caller's trace when it sent a traceparent.
+// the span is named after the ServeMux pattern that ended up serving the request
+func Middleware(provider trace.TracerProvider, next http.Handler) http.Handler {
+	tracer := provider.Tracer(tracerName)
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		ctx := Propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := tracer.Start(ctx, req.Method, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(req.Method),
+				semconv.URLPath(req.URL.Path),
+			))
+		defer span.End()
+
+		tracedReq := req.WithContext(ctx)
+		writer := &statusRecorder{ResponseWriter: res, status: 200}
+		next.ServeHTTP(writer, tracedReq)
+
+		//the mux sets Pattern on the request it was handed, which is this one
+		if route := tracedReq.Pattern; route != "" {
+			span.SetAttributes(semconv.HTTPRoute(route))
+			if !strings.Contains(route, " ") {
+				route = req.Method + " " + route
+			}
+			span.SetName(route)
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(writer.status))
+		if writer.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(writer.status))
+		}
+	})
+}
+
+// only here for the status code. Unwrap and Hijack keep streaming and websockets working
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
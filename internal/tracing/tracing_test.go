@@ -0,0 +1,134 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)), exporter
+}
+
+func spanAttr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+// answers Exec with one row affected and fails every Query
+type fakeDBTX struct{}
+
+var errQueryFailed = errors.New("query failed")
+
+func (fakeDBTX) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+func (fakeDBTX) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, errQueryFailed
+}
+func (fakeDBTX) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, errQueryFailed
+}
+func (fakeDBTX) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return &sql.Row{}
+}
+
+func TestMiddleware(t *testing.T) {
+	provider, exporter := newTestProvider()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirpId}", func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(500)
+	})
+	handler := Middleware(provider, mux)
+
+	//test1 - the span continues the caller's trace and is named after the route
+	req := httptest.NewRequest("GET", "/api/chirps/123", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("test-FAIL: expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /api/chirps/{chirpId}" || span.SpanKind != trace.SpanKindServer {
+		t.Fatalf("test-FAIL: expected a server span named after the route, got %s %s", span.SpanKind, span.Name)
+	}
+	if span.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("test-FAIL: expected the traceparent's trace and parent, got %s %s", span.SpanContext.TraceID(), span.Parent.SpanID())
+	} else {
+		t.Logf("test-PASS: %s joined trace %s", span.Name, span.SpanContext.TraceID())
+	}
+
+	//test2 - status code and route are recorded, a 500 marks the span as failed
+	if spanAttr(span, "http.response.status_code").AsInt64() != 500 || spanAttr(span, "http.route").AsString() != "GET /api/chirps/{chirpId}" {
+		t.Fatalf("test-FAIL: expected status 500 and the route, got %v", span.Attributes)
+	}
+	if span.Status.Code != codes.Error {
+		t.Fatalf("test-FAIL: expected an error status, got %v", span.Status)
+	} else {
+		t.Logf("test-PASS: status %v", span.Status)
+	}
+
+	//test3 - without a traceparent a new trace is started
+	exporter.Reset()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/nope", nil))
+	spans = exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Parent.IsValid() || spans[0].Name != "GET" {
+		t.Fatalf("test-FAIL: expected one root span named GET, got %+v", spans)
+	} else {
+		t.Logf("test-PASS: root span for an unmatched route")
+	}
+}
+
+func TestWrapDBTX(t *testing.T) {
+	provider, exporter := newTestProvider()
+	queries := database.New(WrapDBTX(fakeDBTX{}, provider))
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+
+	//test1 - a generated query gets a client span under the caller's span
+	if err := queries.ResetUsers(ctx); err != nil {
+		t.Fatalf("test-FAIL: ResetUsers failed: %v", err)
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("test-FAIL: expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "ResetUsers" || span.SpanKind != trace.SpanKindClient || span.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("test-FAIL: expected a ResetUsers client span under the request, got %s %s", span.SpanKind, span.Name)
+	}
+	if spanAttr(span, "db.system").AsString() != "postgresql" || spanAttr(span, "db.rows_affected").AsInt64() != 1 {
+		t.Fatalf("test-FAIL: missing db attributes, got %v", span.Attributes)
+	} else {
+		t.Logf("test-PASS: %s span with %v", span.Name, span.Attributes)
+	}
+
+	//test2 - a failing query marks its span as failed
+	exporter.Reset()
+	if _, err := queries.GetReports(ctx, database.GetReportsParams{Status: "open", Limit: 10}); !errors.Is(err, errQueryFailed) {
+		t.Fatalf("test-FAIL: expected the query error back, got %v", err)
+	}
+	spans = exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "GetReports" || spans[0].Status.Code != codes.Error {
+		t.Fatalf("test-FAIL: expected a failed GetReports span, got %+v", spans)
+	} else {
+		t.Logf("test-PASS: %s span %v", spans[0].Name, spans[0].Status)
+	}
+	parent.End()
+}
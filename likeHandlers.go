@@ -0,0 +1,98 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+)
+
+// likes only exist to tell the author, "5 people liked your chirp"
+
+func (cfg *apiConfig) LikeChirp(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	reqChirpId, err := uuid.Parse(req.PathValue("chirpId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+	dbChirp, err := cfg.DB.GetOneChirp(req.Context(), reqChirpId)
+	if errors.Is(err, sql.ErrNoRows) {
+		ErrorResponseWriter(res, "failed to find chirp with provided id in DB", err, 404)
+		return
+	}
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for chirp in DB", err, 500)
+		return
+	}
+	//chirps nobody else can see can't be liked either
+	if dbChirp.HiddenAt.Valid || (dbChirp.ShadowLimitedAt.Valid && dbChirp.UserID != userID) {
+		err := errors.New("chirp not visible")
+		ErrorResponseWriter(res, "failed to find chirp with provided id in DB", err, 404)
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to start DB transaction", err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	created, err := qtx.CreateChirpLike(req.Context(),
+		database.CreateChirpLikeParams{UserID: userID, ChirpID: dbChirp.ID})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to write like to DB", err, 500)
+		return
+	}
+	//liking again is a no-op and doesn't bump the author's count
+	if created > 0 {
+		if err := notifyUser(req.Context(), qtx, dbChirp.UserID, notifyChirpLiked,
+			uuid.NullUUID{UUID: dbChirp.ID, Valid: true}, uuid.NullUUID{UUID: userID, Valid: true}); err != nil {
+			ErrorResponseWriter(res, "Failed to write notification to DB", err, 500)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		ErrorResponseWriter(res, "Failed to commit DB transaction", err, 500)
+		return
+	}
+	res.WriteHeader(204)
+}
+
+func (cfg *apiConfig) UnlikeChirp(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	reqChirpId, err := uuid.Parse(req.PathValue("chirpId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+
+	deleted, err := cfg.DB.DeleteChirpLike(req.Context(),
+		database.DeleteChirpLikeParams{UserID: userID, ChirpID: reqChirpId})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to delete like in DB", err, 500)
+		return
+	}
+	if deleted == 0 {
+		err := errors.New("like not found")
+		ErrorResponseWriter(res, "you have not liked this chirp", err, 404)
+		return
+	}
+	res.WriteHeader(204)
+}
@@ -1,46 +1,132 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
+	"os/signal"
 	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/JettMingin/chirpy-bootdev/internal/clientip"
 	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/JettMingin/chirpy-bootdev/internal/dbmetrics"
+	"github.com/JettMingin/chirpy-bootdev/internal/moderation"
+	"github.com/JettMingin/chirpy-bootdev/internal/pubsub"
+	"github.com/JettMingin/chirpy-bootdev/internal/tracing"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type apiConfig struct {
-	fileServerHits atomic.Int32
-	DB             *database.Queries
-	Platform       string
-	TokenSecret    string
-	PolkaKey       string
+	fileServerHitsAtReset atomic.Int64
+	Metrics               *chirpyMetrics
+	MetricsToken          string
+	DB                    *database.Queries
+	DBConn                *sql.DB
+	TracerProvider        trace.TracerProvider
+	RateLimiter           *rateLimiter
+	Platform              string
+	AdminEmails           map[string]bool
+	TrustedProxies        []netip.Prefix
+	TokenSecret           string
+	PolkaKey              string
+	PolkaWebhookSecrets   []string
+	ChirpEvents           *pubsub.Broker[streamEvent]
+	NotificationEvents    *pubsub.Topics[uuid.UUID, Notification]
+	WSConns               *wsConnLimiter
+	ContentFilter         *moderation.Engine
+}
+
+// use this instead of cfg.DB.WithTx, which would drop the tracing wrapper
+func (cfg *apiConfig) withTx(tx *sql.Tx) *database.Queries {
+	return database.New(tracing.WrapDBTX(tx, cfg.TracerProvider))
+}
+
+// outermost first, so 429s and suspensions still show up in traces, logs and metrics
+func (cfg *apiConfig) middlewareChain(next http.Handler) http.Handler {
+	return tracing.Middleware(cfg.TracerProvider, cfg.middlewareRequestLog(cfg.middlewareMetrics(
+		cfg.middlewareRateLimit(cfg.middlewareRejectSuspended(next)))))
 }
 
 func main() {
 	//set up env
 	godotenv.Load()
+	//log.Fatal and anything else on the log package goes through this too once it's the default
+	slog.SetDefault(newLogger(os.Getenv("LOG_LEVEL")))
 	dbURL := os.Getenv("DB_URL")
 	platform := os.Getenv("PLATFORM")
 	superSecret := os.Getenv("TOKEN_SECRET")
 	polkaKey := os.Getenv("POLKA_KEY")
+	metricsToken := os.Getenv("METRICS_TOKEN")
+	//load balancers allowed to say who the client is in X-Forwarded-For, none by default
+	trustedProxies, err := clientip.ParseTrusted(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		panic(err)
+	}
+	//current webhook secret first, the previous one stays valid while rotating
+	polkaWebhookSecrets := []string{}
+	for _, secretEnv := range []string{"POLKA_WEBHOOK_SECRET", "POLKA_WEBHOOK_SECRET_PREVIOUS"} {
+		if secret := os.Getenv(secretEnv); secret != "" {
+			polkaWebhookSecrets = append(polkaWebhookSecrets, secret)
+		}
+	}
+
+	//OTEL_TRACES_EXPORTER is otlp, stdout or none (the default)
+	tracerProvider, shutdownTracing, err := tracing.Setup(context.Background(), os.Getenv("OTEL_TRACES_EXPORTER"))
+	if err != nil {
+		panic(err)
+	}
 
-	//set up db
-	db, err := sql.Open("postgres", dbURL)
+	//set up db, every statement is timed for /metrics and traced
+	metrics := newChirpyMetrics()
+	pqConnector, err := pq.NewConnector(dbURL)
 	if err != nil {
 		panic(err)
 	}
-	dbQueries := database.New(db)
+	db := sql.OpenDB(dbmetrics.NewConnector(pqConnector,
+		func(ctx context.Context, query string, duration time.Duration, err error) {
+			metrics.observeQuery(query, duration, err)
+			logQuery(ctx, query, duration, err)
+		}))
+	dbQueries := database.New(tracing.WrapDBTX(db, tracerProvider))
 
 	apiCfg := &apiConfig{
-		DB:          dbQueries,
-		Platform:    platform,
-		TokenSecret: superSecret,
-		PolkaKey:    polkaKey,
+		Metrics:             metrics,
+		MetricsToken:        metricsToken,
+		DB:                  dbQueries,
+		DBConn:              db,
+		TracerProvider:      tracerProvider,
+		Platform:            platform,
+		AdminEmails:         parseAdminEmails(os.Getenv("ADMIN_EMAILS")),
+		TrustedProxies:      trustedProxies,
+		TokenSecret:         superSecret,
+		PolkaKey:            polkaKey,
+		PolkaWebhookSecrets: polkaWebhookSecrets,
+		ChirpEvents:         pubsub.NewBroker[streamEvent](streamBufferSize),
+		NotificationEvents:  pubsub.NewTopics[uuid.UUID, Notification](streamBufferSize),
+		WSConns:             newWSConnLimiter(),
+		ContentFilter:       moderation.NewEngine(defaultFilterRules),
+	}
+	//RATE_LIMIT_READ and RATE_LIMIT_WRITE look like 300/1m, the backend is memory, postgres or none
+	apiCfg.RateLimiter, err = newRateLimiter(os.Getenv("RATE_LIMIT_BACKEND"),
+		os.Getenv("RATE_LIMIT_READ"), os.Getenv("RATE_LIMIT_WRITE"), dbQueries)
+	if err != nil {
+		panic(err)
+	}
+	if metricsToken == "" && platform != "dev" {
+		slog.Warn("METRICS_TOKEN is not set, /metrics will refuse every scrape")
+	}
+	if err := apiCfg.reloadContentFilter(context.Background()); err != nil {
+		slog.Warn("loading filter rules, using the defaults", "error", err)
 	}
 
 	servemux := http.NewServeMux() //multiplex (seems to work like my map-based router in deno)
@@ -53,8 +139,10 @@ func main() {
 		res.WriteHeader(http.StatusOK)
 		res.Write([]byte("OK"))
 	})
+	servemux.Handle("GET /metrics", apiCfg.metricsEndpoint())
 
-	servemux.HandleFunc("POST /api/users", apiCfg.postUser)
+	//creates take an Idempotency-Key so retried requests don't create twice
+	servemux.HandleFunc("POST /api/users", apiCfg.idempotent(apiCfg.postUser))
 	servemux.HandleFunc("POST /api/login", apiCfg.Login)
 	servemux.HandleFunc("PUT /api/users", apiCfg.UpdateUser)
 	servemux.HandleFunc("POST /api/refresh", apiCfg.RefreshAccessToken)
@@ -62,23 +150,132 @@ func main() {
 
 	servemux.HandleFunc("GET /api/chirps", apiCfg.getChirps)
 	servemux.HandleFunc("GET /api/chirps/{chirpId}", apiCfg.getChirp)
-	servemux.HandleFunc("POST /api/chirps", apiCfg.postChirp)
+	servemux.HandleFunc("POST /api/chirps", apiCfg.idempotent(apiCfg.postChirp))
+	servemux.HandleFunc("PUT /api/chirps/{chirpId}", apiCfg.UpdateChirp)
 	servemux.HandleFunc("DELETE /api/chirps/{chirpId}", apiCfg.DeleteChirp)
+	servemux.HandleFunc("GET /api/stream", apiCfg.StreamChirps)
+	servemux.HandleFunc("GET /api/ws", apiCfg.ServeWebSocket)
+
+	servemux.HandleFunc("GET /api/scheduled_chirps", apiCfg.GetScheduledChirps)
+	servemux.HandleFunc("PUT /api/scheduled_chirps/{scheduledId}", apiCfg.RescheduleChirp)
+	servemux.HandleFunc("DELETE /api/scheduled_chirps/{scheduledId}", apiCfg.CancelScheduledChirp)
+
+	servemux.HandleFunc("GET /api/drafts", apiCfg.GetDrafts)
+	servemux.HandleFunc("POST /api/drafts", apiCfg.idempotent(apiCfg.PostDraft))
+	servemux.HandleFunc("GET /api/drafts/{draftId}", apiCfg.GetDraft)
+	servemux.HandleFunc("PUT /api/drafts/{draftId}", apiCfg.UpdateDraft)
+	servemux.HandleFunc("DELETE /api/drafts/{draftId}", apiCfg.DeleteDraft)
+	servemux.HandleFunc("POST /api/drafts/{draftId}/publish", apiCfg.idempotent(apiCfg.PublishDraft))
+
+	servemux.HandleFunc("POST /api/chirps/{chirpId}/poll/vote", apiCfg.VotePoll)
+	servemux.HandleFunc("POST /api/chirps/{chirpId}/like", apiCfg.LikeChirp)
+	servemux.HandleFunc("DELETE /api/chirps/{chirpId}/like", apiCfg.UnlikeChirp)
+
+	servemux.HandleFunc("POST /api/chirps/{chirpId}/bookmark", apiCfg.PostBookmark)
+	servemux.HandleFunc("PUT /api/chirps/{chirpId}/bookmark", apiCfg.MoveBookmark)
+	servemux.HandleFunc("DELETE /api/chirps/{chirpId}/bookmark", apiCfg.DeleteBookmark)
+	servemux.HandleFunc("GET /api/users/me/bookmarks", apiCfg.GetBookmarks)
+	servemux.HandleFunc("GET /api/users/me/bookmarks/folders", apiCfg.GetBookmarkFolders)
+	servemux.HandleFunc("POST /api/users/me/bookmarks/folders", apiCfg.idempotent(apiCfg.PostBookmarkFolder))
+	servemux.HandleFunc("DELETE /api/users/me/bookmarks/folders/{folderId}", apiCfg.DeleteBookmarkFolder)
+
+	servemux.HandleFunc("POST /api/chirps/{chirpId}/report", apiCfg.idempotent(apiCfg.ReportChirp))
+	servemux.HandleFunc("POST /api/users/{userId}/report", apiCfg.idempotent(apiCfg.ReportUser))
+
+	servemux.HandleFunc("POST /api/users/{userId}/block", apiCfg.BlockUser)
+	servemux.HandleFunc("DELETE /api/users/{userId}/block", apiCfg.UnblockUser)
+	servemux.HandleFunc("POST /api/users/{userId}/mute", apiCfg.MuteUser)
+	servemux.HandleFunc("DELETE /api/users/{userId}/mute", apiCfg.UnmuteUser)
+	servemux.HandleFunc("POST /api/users/{userId}/follow", apiCfg.FollowUser)
+	servemux.HandleFunc("DELETE /api/users/{userId}/follow", apiCfg.UnfollowUser)
+
+	servemux.HandleFunc("GET /api/conversations", apiCfg.GetConversations)
+	servemux.HandleFunc("POST /api/conversations", apiCfg.idempotent(apiCfg.PostConversation))
+	servemux.HandleFunc("GET /api/conversations/{conversationId}/messages", apiCfg.GetMessages)
+	servemux.HandleFunc("POST /api/conversations/{conversationId}/messages", apiCfg.idempotent(apiCfg.PostMessage))
+	servemux.HandleFunc("POST /api/conversations/{conversationId}/read", apiCfg.MarkConversationRead)
+
+	servemux.HandleFunc("GET /api/notifications", apiCfg.GetNotifications)
+	servemux.HandleFunc("POST /api/notifications/read", apiCfg.MarkNotificationsRead)
+	servemux.HandleFunc("GET /api/notifications/mutes", apiCfg.GetNotificationMutes)
+	servemux.HandleFunc("PUT /api/notifications/mutes/{type}", apiCfg.MuteNotificationType)
+	servemux.HandleFunc("DELETE /api/notifications/mutes/{type}", apiCfg.UnmuteNotificationType)
 
 	servemux.HandleFunc("POST /api/polka/webhooks", apiCfg.UpgradeUser)
 
-	servemux.HandleFunc("GET /admin/metrics", apiCfg.metricsHandler)
-	servemux.HandleFunc("POST /admin/reset", apiCfg.postToReset)
+	servemux.HandleFunc("GET /api/webhooks", apiCfg.GetWebhooks)
+	servemux.HandleFunc("POST /api/webhooks", apiCfg.idempotent(apiCfg.PostWebhook))
+	servemux.HandleFunc("DELETE /api/webhooks/{webhookId}", apiCfg.DeleteWebhook)
+	servemux.HandleFunc("GET /api/webhooks/{webhookId}/deliveries", apiCfg.GetWebhookDeliveries)
+	servemux.HandleFunc("POST /api/webhooks/{webhookId}/deliveries/{deliveryId}/retry", apiCfg.RedriveWebhookDelivery)
+
+	//every /admin route goes through the role check first
+	adminMux := http.NewServeMux()
+	adminMux.HandleFunc("GET /admin/metrics", apiCfg.metricsHandler)
+	adminMux.HandleFunc("POST /admin/reset", apiCfg.postToReset)
+	adminMux.HandleFunc("GET /admin/webhooks", apiCfg.GetAdminWebhooks)
+	adminMux.HandleFunc("POST /admin/webhooks", apiCfg.PostAdminWebhook)
+	adminMux.HandleFunc("DELETE /admin/webhooks/{webhookId}", apiCfg.DeleteAdminWebhook)
+	adminMux.HandleFunc("GET /admin/webhooks/{webhookId}/deliveries", apiCfg.GetAdminWebhookDeliveries)
+	adminMux.HandleFunc("POST /admin/webhooks/{webhookId}/deliveries/{deliveryId}/retry", apiCfg.RedriveAdminWebhookDelivery)
+
+	adminMux.HandleFunc("GET /admin/moderation/reports", apiCfg.GetReports)
+	adminMux.HandleFunc("POST /admin/moderation/reports/{reportId}/claim", apiCfg.ClaimReport)
+	adminMux.HandleFunc("POST /admin/moderation/reports/{reportId}/resolve", apiCfg.ResolveReport)
+	adminMux.HandleFunc("GET /admin/moderation/actions", apiCfg.GetModerationActions)
+	adminMux.HandleFunc("GET /admin/moderation/filters", apiCfg.GetFilterRules)
+	adminMux.HandleFunc("POST /admin/moderation/filters", apiCfg.PostFilterRule)
+	adminMux.HandleFunc("PUT /admin/moderation/filters/{ruleId}", apiCfg.UpdateFilterRule)
+	adminMux.HandleFunc("DELETE /admin/moderation/filters/{ruleId}", apiCfg.DeleteFilterRule)
+	adminMux.HandleFunc("GET /admin/moderation/spam/decisions", apiCfg.GetSpamDecisions)
+	adminMux.HandleFunc("POST /admin/moderation/spam/decisions/{decisionId}/review", apiCfg.ReviewSpamDecision)
+	adminMux.HandleFunc("GET /admin/moderation/spam/settings", apiCfg.GetSpamSettings)
+	adminMux.HandleFunc("PUT /admin/moderation/spam/settings", apiCfg.UpdateSpamSettings)
+
+	adminMux.HandleFunc("POST /admin/users/{userId}/suspend", apiCfg.SuspendUser)
+	adminMux.HandleFunc("POST /admin/users/{userId}/unsuspend", apiCfg.UnsuspendUser)
+	adminMux.HandleFunc("PUT /admin/users/{userId}/role", apiCfg.SetUserRole)
+
+	adminMux.HandleFunc("GET /admin/audit", apiCfg.GetAuditEvents)
+
+	servemux.Handle("/admin/", apiCfg.middlewareAdminAuth(adminMux))
 
 	//----------------------------------------------------------------------
 
+	go apiCfg.runScheduledChirpPublisher(context.Background(), 15*time.Second)
+	go apiCfg.runSubscriptionExpirer(context.Background(), 10*time.Minute)
+	go apiCfg.runWebhookDispatcher(context.Background(), 5*time.Second)
+	go apiCfg.runEventListener(context.Background(), dbURL)
+	go apiCfg.runIdempotencyKeyPruner(context.Background(), time.Hour)
+	if apiCfg.RateLimiter != nil {
+		go apiCfg.runRateLimitPruner(context.Background(), time.Minute)
+	}
+
+	//WriteTimeout still applies to every normal response, /api/stream lifts it for itself
 	s := &http.Server{
 		Addr:           ":8080",
-		Handler:        servemux,
+		Handler:        apiCfg.middlewareChain(servemux),
 		ReadTimeout:    10 * time.Second,
 		WriteTimeout:   10 * time.Second,
 		MaxHeaderBytes: 1 << 20,
 	}
 
-	log.Fatal(s.ListenAndServe())
+	//run until interrupted, then flush whatever spans are still buffered before exiting
+	stopCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		if err := s.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+	<-stopCtx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("shutting down the server", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Warn("flushing traces", "error", err)
+	}
 }
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/auth"
+	"github.com/JettMingin/chirpy-bootdev/internal/dbmetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// label values for the business counters
+const (
+	chirpSourceAPI       = "api"
+	chirpSourceDraft     = "draft"
+	chirpSourceScheduled = "scheduled"
+	loginSuccess         = "success"
+	loginFailure         = "failure"
+	streamSSE            = "sse"
+	streamWebSocket      = "websocket"
+)
+
+// anything else is counted as "other" so junk methods can't blow up the label set
+var metricsMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// everything /metrics serves. kept on its own registry so tests and tools can build another
+type chirpyMetrics struct {
+	registry          *prometheus.Registry
+	httpRequests      *prometheus.CounterVec
+	httpDuration      *prometheus.HistogramVec
+	dbQueryDuration   *prometheus.HistogramVec
+	streamConnections *prometheus.GaugeVec
+	fileServerHits    prometheus.Counter
+	chirpsCreated     *prometheus.CounterVec
+	logins            *prometheus.CounterVec
+}
+
+func newChirpyMetrics() *chirpyMetrics {
+	m := &chirpyMetrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_http_requests_total",
+			Help: "HTTP requests served, by route pattern, method and status code.",
+		}, []string{"route", "method", "code"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_http_request_duration_seconds",
+			Help:    "Time to serve an HTTP request, by route pattern and method. Streams count until they close.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		dbQueryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_db_query_duration_seconds",
+			Help:    "Time until postgres answered a statement, by sqlc query name and outcome.",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"query", "outcome"}),
+		streamConnections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "chirpy_stream_connections",
+			Help: "Open SSE and WebSocket connections on this instance.",
+		}, []string{"transport"}),
+		fileServerHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_fileserver_hits_total",
+			Help: "Requests served from /app/.",
+		}),
+		chirpsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_chirps_created_total",
+			Help: "Chirps written, by where they came from.",
+		}, []string{"source"}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_logins_total",
+			Help: "Login attempts, by result.",
+		}, []string{"result"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.dbQueryDuration,
+		m.streamConnections,
+		m.fileServerHits,
+		m.chirpsCreated,
+		m.logins,
+	)
+	//start every known series at zero so rate() works from the first scrape
+	for _, source := range []string{chirpSourceAPI, chirpSourceDraft, chirpSourceScheduled} {
+		m.chirpsCreated.WithLabelValues(source)
+	}
+	for _, result := range []string{loginSuccess, loginFailure} {
+		m.logins.WithLabelValues(result)
+	}
+	for _, transport := range []string{streamSSE, streamWebSocket} {
+		m.streamConnections.WithLabelValues(transport)
+	}
+	return m
+}
+
+// dbmetrics.Observer for the postgres connector
+func (m *chirpyMetrics) observeQuery(query string, duration time.Duration, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	m.dbQueryDuration.WithLabelValues(dbmetrics.QueryName(query), outcome).Observe(duration.Seconds())
+}
+
+// sums every series of one metric, optionally only those with labelName=labelValue.
+// this is what the admin page reads, so it shows the same numbers /metrics does
+func (m *chirpyMetrics) total(name, labelName, labelValue string) float64 {
+	families, err := m.registry.Gather()
+	if err != nil {
+		return 0
+	}
+	sum := 0.0
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if labelName != "" && !hasLabel(metric, labelName, labelValue) {
+				continue
+			}
+			switch {
+			case metric.GetCounter() != nil:
+				sum += metric.GetCounter().GetValue()
+			case metric.GetGauge() != nil:
+				sum += metric.GetGauge().GetValue()
+			}
+		}
+	}
+	return sum
+}
+
+func hasLabel(metric *dto.Metric, name, value string) bool {
+	for _, label := range metric.GetLabel() {
+		if label.GetName() == name {
+			return label.GetValue() == value
+		}
+	}
+	return false
+}
+
+// keeps the status code, body size and any ErrorResponseWriter error for the request metrics
+// and logs. Unwrap lets http.ResponseController reach the real writer and Hijack keeps
+// websocket upgrades working through it
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+	errMsg string
+	err    error
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// a hijacked connection answers with 101 on its own, the writer never sees it
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// sits inside the request log in middlewareChain and outside the rate limiter, so 429s are counted.
+// times every request and labels it with the route pattern that served it
+func (cfg *apiConfig) middlewareMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		writer := &statusWriter{ResponseWriter: res, status: 200}
+		next.ServeHTTP(writer, req)
+
+		//the mux sets Pattern on the request it was handed, which is this one
+		route := req.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		method := "other"
+		if slices.Contains(metricsMethods, req.Method) {
+			method = req.Method
+		}
+		cfg.Metrics.httpRequests.WithLabelValues(route, method, strconv.Itoa(writer.status)).Inc()
+		cfg.Metrics.httpDuration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// prometheus text format, scrapers send METRICS_TOKEN as a bearer token.
+// without a token set it is only served on PLATFORM=dev, the routes and error counts aren't public
+func (cfg *apiConfig) metricsEndpoint() http.Handler {
+	promHandler := promhttp.HandlerFor(cfg.Metrics.registry, promhttp.HandlerOpts{})
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if cfg.MetricsToken == "" && cfg.Platform != "dev" {
+			err := errors.New("METRICS_TOKEN is not set")
+			res.Header().Set("Content-Type", "application/json")
+			ErrorResponseWriter(res, "metrics are disabled until METRICS_TOKEN is set", err, 403)
+			return
+		}
+		if cfg.MetricsToken != "" {
+			token, err := auth.GetBearerToken(req.Header)
+			if err == nil && subtle.ConstantTimeCompare([]byte(token), []byte(cfg.MetricsToken)) != 1 {
+				err = errors.New("wrong metrics token")
+			}
+			if err != nil {
+				res.Header().Set("Content-Type", "application/json")
+				ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+				return
+			}
+		}
+		promHandler.ServeHTTP(res, req)
+	})
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+)
+
+// what a moderator can do when resolving a report. claiming and the admin user actions are
+// logged to the same trail
+const (
+	moderationClaim         = "claim"
+	moderationHideChirp     = "hide_chirp"
+	moderationDeleteChirp   = "delete_chirp"
+	moderationSuspendUser   = "suspend_user"
+	moderationDismiss       = "dismiss"
+	moderationUnsuspendUser = "unsuspend_user"
+	moderationSetRole       = "set_role"
+)
+
+var moderationResolutions = []string{moderationHideChirp, moderationDeleteChirp, moderationSuspendUser, moderationDismiss}
+
+const maxModerationNotesLength = 1000
+
+type ModerationAction struct {
+	ID           uuid.UUID  `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ModeratorID  *uuid.UUID `json:"moderator_id"`
+	ReportID     *uuid.UUID `json:"report_id"`
+	Action       string     `json:"action"`
+	ChirpID      *uuid.UUID `json:"chirp_id"`
+	TargetUserID *uuid.UUID `json:"target_user_id"`
+	Notes        string     `json:"notes"`
+}
+
+// the moderator middlewareAdminAuth let through. writes the error response itself if there isn't one
+func (cfg *apiConfig) getModeratorID(res http.ResponseWriter, req *http.Request) (uuid.UUID, bool) {
+	dbUser, ok := adminUserFromContext(req.Context())
+	if !ok {
+		err := errors.New("no moderator on request")
+		ErrorResponseWriter(res, "Moderator Access Required", err, 403)
+		return uuid.UUID{}, false
+	}
+	return dbUser.ID, true
+}
+
+func (cfg *apiConfig) GetReports(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	if _, ok := cfg.getModeratorID(res, req); !ok {
+		return
+	}
+	status := req.URL.Query().Get("status")
+	if status == "" {
+		status = reportOpen
+	}
+	if !slices.Contains([]string{reportOpen, reportClaimed, reportResolved}, status) {
+		err := errors.New("unknown report status")
+		ErrorResponseWriter(res, "status must be open, claimed or resolved", err, 400)
+		return
+	}
+	limit, offset, err := getPaginationParams(req)
+	if err != nil {
+		ErrorResponseWriter(res, "invalid pagination params", err, 400)
+		return
+	}
+
+	dbReports, err := cfg.DB.GetReports(req.Context(), database.GetReportsParams{
+		Status: status,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for reports in DB", err, 500)
+		return
+	}
+
+	selectedReports := []Report{}
+	for _, row := range dbReports {
+		selectedReports = append(selectedReports, reportFromDB(row))
+	}
+
+	successRes, err := json.Marshal(selectedReports)
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) ClaimReport(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	moderatorID, ok := cfg.getModeratorID(res, req)
+	if !ok {
+		return
+	}
+	reportID, err := uuid.Parse(req.PathValue("reportId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to start DB transaction", err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	//row lock so two moderators can't claim the same report at once
+	dbReport, err := qtx.GetReportForUpdate(req.Context(), reportID)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to find report with provided id in DB", err, 404)
+		return
+	}
+	if dbReport.Status == reportResolved {
+		err := errors.New("report already resolved")
+		ErrorResponseWriter(res, "report has already been resolved", err, 409)
+		return
+	}
+	if dbReport.Status == reportClaimed && dbReport.ClaimedBy.UUID != moderatorID {
+		err := errors.New("report claimed by another moderator")
+		ErrorResponseWriter(res, "report is claimed by another moderator", err, 409)
+		return
+	}
+
+	moderatorNullID := uuid.NullUUID{UUID: moderatorID, Valid: true}
+	dbReport, err = qtx.ClaimReport(req.Context(), database.ClaimReportParams{ClaimedBy: moderatorNullID, ID: reportID})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to claim report in DB", err, 500)
+		return
+	}
+	if err := qtx.CreateModerationAction(req.Context(), database.CreateModerationActionParams{
+		ModeratorID:  moderatorNullID,
+		ReportID:     uuid.NullUUID{UUID: reportID, Valid: true},
+		Action:       moderationClaim,
+		ChirpID:      dbReport.ChirpID,
+		TargetUserID: dbReport.TargetUserID,
+	}); err != nil {
+		ErrorResponseWriter(res, "Failed to write moderation action to DB", err, 500)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		ErrorResponseWriter(res, "Failed to commit DB transaction", err, 500)
+		return
+	}
+
+	successRes, err := json.Marshal(reportFromDB(dbReport))
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+// applies the chosen action and closes the report in one transaction. hiding or deleting a chirp
+// also closes every other open report against that chirp
+func (cfg *apiConfig) ResolveReport(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	moderatorID, ok := cfg.getModeratorID(res, req)
+	if !ok {
+		return
+	}
+	reportID, err := uuid.Parse(req.PathValue("reportId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+
+	type ResolveRequest struct {
+		Action string `json:"action"`
+		Notes  string `json:"notes"`
+	}
+	reqData, err := io.ReadAll(req.Body)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to read request body", err, 500)
+		return
+	}
+	var resolveReq ResolveRequest
+	if err := json.Unmarshal(reqData, &resolveReq); err != nil {
+		ErrorResponseWriter(res, "Failed to decode request body", err, 400)
+		return
+	}
+	if !slices.Contains(moderationResolutions, resolveReq.Action) {
+		err := errors.New("unknown moderation action")
+		ErrorResponseWriter(res, "action must be hide_chirp, delete_chirp, suspend_user or dismiss", err, 400)
+		return
+	}
+	if len(resolveReq.Notes) > maxModerationNotesLength {
+		err := errors.New("notes too long")
+		ErrorResponseWriter(res, "notes must be 1000 characters or fewer", err, 400)
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to start DB transaction", err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	dbReport, err := qtx.GetReportForUpdate(req.Context(), reportID)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to find report with provided id in DB", err, 404)
+		return
+	}
+	if dbReport.Status == reportResolved {
+		err := errors.New("report already resolved")
+		ErrorResponseWriter(res, "report has already been resolved", err, 409)
+		return
+	}
+	if dbReport.Status == reportClaimed && dbReport.ClaimedBy.UUID != moderatorID {
+		err := errors.New("report claimed by another moderator")
+		ErrorResponseWriter(res, "report is claimed by another moderator", err, 409)
+		return
+	}
+
+	moderatorNullID := uuid.NullUUID{UUID: moderatorID, Valid: true}
+	resolution := sql.NullString{String: resolveReq.Action, Valid: true}
+	chirpAction := resolveReq.Action == moderationHideChirp || resolveReq.Action == moderationDeleteChirp
+	if chirpAction && !dbReport.ChirpID.Valid {
+		err := errors.New("report has no chirp")
+		ErrorResponseWriter(res, "report is not about a chirp, or the chirp is already gone", err, 400)
+		return
+	}
+	if resolveReq.Action == moderationSuspendUser && !dbReport.TargetUserID.Valid {
+		err := errors.New("report has no target user")
+		ErrorResponseWriter(res, "reported user no longer exists", err, 400)
+		return
+	}
+
+	//resolve first, deleting the chirp nulls chirp_id on its reports
+	dbReport, err = qtx.ResolveReport(req.Context(), database.ResolveReportParams{
+		ResolvedBy: moderatorNullID,
+		Resolution: resolution,
+		ID:         reportID,
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to resolve report in DB", err, 500)
+		return
+	}
+	if chirpAction {
+		if err := qtx.ResolveOpenChirpReports(req.Context(), database.ResolveOpenChirpReportsParams{
+			ResolvedBy: moderatorNullID,
+			Resolution: resolution,
+			ChirpID:    dbReport.ChirpID,
+		}); err != nil {
+			ErrorResponseWriter(res, "Failed to resolve related reports in DB", err, 500)
+			return
+		}
+	}
+
+	switch resolveReq.Action {
+	case moderationHideChirp:
+		dbChirp, err := qtx.GetOneChirp(req.Context(), dbReport.ChirpID.UUID)
+		if err != nil {
+			ErrorResponseWriter(res, "failed to find chirp with provided id in DB", err, 404)
+			return
+		}
+		hidden, err := qtx.HideChirp(req.Context(), dbChirp.ID)
+		if err != nil {
+			ErrorResponseWriter(res, "Failed to hide chirp in DB", err, 500)
+			return
+		}
+		//subscribers see it go like a delete, the stream event comes from the chirps_record_hide trigger.
+		//shadow limited chirps were never announced, so there's nothing to take back
+		if hidden > 0 && !dbChirp.ShadowLimitedAt.Valid {
+			if err := enqueueWebhookEvent(req.Context(), qtx, webhookChirpDeleted, chirpFromDB(dbChirp)); err != nil {
+				ErrorResponseWriter(res, "Failed to queue webhook event", err, 500)
+				return
+			}
+		}
+	case moderationDeleteChirp:
+		dbChirp, err := qtx.GetOneChirp(req.Context(), dbReport.ChirpID.UUID)
+		if err != nil {
+			ErrorResponseWriter(res, "failed to find chirp with provided id in DB", err, 404)
+			return
+		}
+		if err := qtx.DeleteOneChirp(req.Context(), dbChirp.ID); err != nil {
+			ErrorResponseWriter(res, "Failed to delete chirp in DB", err, 500)
+			return
+		}
+		if !dbChirp.HiddenAt.Valid && !dbChirp.ShadowLimitedAt.Valid {
+			if err := enqueueWebhookEvent(req.Context(), qtx, webhookChirpDeleted, chirpFromDB(dbChirp)); err != nil {
+				ErrorResponseWriter(res, "Failed to queue webhook event", err, 500)
+				return
+			}
+		}
+		chirpDeleted := cfg.newAuditEvent(req, auditChirpDeleted)
+		chirpDeleted.ActorID = moderatorNullID
+		chirpDeleted.TargetType, chirpDeleted.TargetID = "chirp", dbChirp.ID.String()
+		chirpDeleted.Details["author_id"] = dbChirp.UserID.String()
+		chirpDeleted.Details["report_id"] = reportID.String()
+		if err := appendAuditEvent(req.Context(), qtx, chirpDeleted); err != nil {
+			ErrorResponseWriter(res, "Failed to write audit event to DB", err, 500)
+			return
+		}
+	case moderationSuspendUser:
+		if _, err := qtx.SuspendUser(req.Context(), dbReport.TargetUserID.UUID); err != nil {
+			ErrorResponseWriter(res, "Failed to suspend user in DB", err, 500)
+			return
+		}
+		if err := qtx.RevokeUsersRefreshTokens(req.Context(), dbReport.TargetUserID.UUID); err != nil {
+			ErrorResponseWriter(res, "Failed to revoke refresh tokens in DB", err, 500)
+			return
+		}
+	}
+
+	if err := qtx.CreateModerationAction(req.Context(), database.CreateModerationActionParams{
+		ModeratorID:  moderatorNullID,
+		ReportID:     uuid.NullUUID{UUID: reportID, Valid: true},
+		Action:       resolveReq.Action,
+		ChirpID:      dbReport.ChirpID,
+		TargetUserID: dbReport.TargetUserID,
+		Notes:        resolveReq.Notes,
+	}); err != nil {
+		ErrorResponseWriter(res, "Failed to write moderation action to DB", err, 500)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		ErrorResponseWriter(res, "Failed to commit DB transaction", err, 500)
+		return
+	}
+
+	successRes, err := json.Marshal(reportFromDB(dbReport))
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) GetModerationActions(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	if _, ok := cfg.getModeratorID(res, req); !ok {
+		return
+	}
+	limit, offset, err := getPaginationParams(req)
+	if err != nil {
+		ErrorResponseWriter(res, "invalid pagination params", err, 400)
+		return
+	}
+
+	dbActions, err := cfg.DB.GetModerationActions(req.Context(),
+		database.GetModerationActionsParams{Limit: limit, Offset: offset})
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for moderation actions in DB", err, 500)
+		return
+	}
+
+	selectedActions := []ModerationAction{}
+	for _, row := range dbActions {
+		selectedActions = append(selectedActions, ModerationAction{
+			ID:           row.ID,
+			CreatedAt:    row.CreatedAt,
+			ModeratorID:  nullUUIDPtr(row.ModeratorID),
+			ReportID:     nullUUIDPtr(row.ReportID),
+			Action:       row.Action,
+			ChirpID:      nullUUIDPtr(row.ChirpID),
+			TargetUserID: nullUUIDPtr(row.TargetUserID),
+			Notes:        row.Notes,
+		})
+	}
+
+	successRes, err := json.Marshal(selectedActions)
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+)
+
+type NotificationMute struct {
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) GetNotifications(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	limit, offset, err := getPaginationParams(req)
+	if err != nil {
+		ErrorResponseWriter(res, "invalid pagination params", err, 400)
+		return
+	}
+
+	dbNotifications, err := cfg.DB.GetUsersNotifications(req.Context(), database.GetUsersNotificationsParams{
+		UserID:     userID,
+		UnreadOnly: req.URL.Query().Get("unread") == "true",
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for notifications in DB", err, 500)
+		return
+	}
+	unreadCount, err := cfg.DB.CountUnreadNotifications(req.Context(), userID)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to count unread notifications in DB", err, 500)
+		return
+	}
+
+	selectedNotifications := []Notification{}
+	for _, row := range dbNotifications {
+		selectedNotifications = append(selectedNotifications, notificationFromDB(row))
+	}
+
+	successRes, err := json.Marshal(map[string]any{
+		"unread_count":  unreadCount,
+		"notifications": selectedNotifications,
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+// marks the listed notifications read, or every unread one when no ids are sent
+func (cfg *apiConfig) MarkNotificationsRead(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+
+	type ReadRequest struct {
+		IDs []uuid.UUID `json:"ids"`
+	}
+	reqData, err := io.ReadAll(req.Body)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to read request body", err, 500)
+		return
+	}
+	var readReq ReadRequest
+	if len(reqData) > 0 {
+		if err := json.Unmarshal(reqData, &readReq); err != nil {
+			ErrorResponseWriter(res, "Failed to decode request body", err, 400)
+			return
+		}
+	}
+
+	var markedRead int64
+	if len(readReq.IDs) == 0 {
+		markedRead, err = cfg.DB.MarkAllNotificationsRead(req.Context(), userID)
+	} else {
+		markedRead, err = cfg.DB.MarkNotificationsRead(req.Context(),
+			database.MarkNotificationsReadParams{UserID: userID, Ids: readReq.IDs})
+	}
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to mark notifications read in DB", err, 500)
+		return
+	}
+	unreadCount, err := cfg.DB.CountUnreadNotifications(req.Context(), userID)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to count unread notifications in DB", err, 500)
+		return
+	}
+
+	successRes, err := json.Marshal(map[string]int64{
+		"marked_read":  markedRead,
+		"unread_count": unreadCount,
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) GetNotificationMutes(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	dbMutes, err := cfg.DB.GetNotificationMutes(req.Context(), userID)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for notification mutes in DB", err, 500)
+		return
+	}
+
+	selectedMutes := []NotificationMute{}
+	for _, row := range dbMutes {
+		selectedMutes = append(selectedMutes, NotificationMute{Type: row.Type, CreatedAt: row.CreatedAt})
+	}
+
+	successRes, err := json.Marshal(selectedMutes)
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) MuteNotificationType(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	notificationType := req.PathValue("type")
+	if !slices.Contains(notificationTypes, notificationType) {
+		err := errors.New("unknown notification type")
+		ErrorResponseWriter(res, "invalid notification type", err, 400)
+		return
+	}
+
+	if err := cfg.DB.CreateNotificationMute(req.Context(),
+		database.CreateNotificationMuteParams{UserID: userID, Type: notificationType}); err != nil {
+		ErrorResponseWriter(res, "Failed to write notification mute to DB", err, 500)
+		return
+	}
+	res.WriteHeader(204)
+}
+
+func (cfg *apiConfig) UnmuteNotificationType(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+
+	deleted, err := cfg.DB.DeleteNotificationMute(req.Context(),
+		database.DeleteNotificationMuteParams{UserID: userID, Type: req.PathValue("type")})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to delete notification mute in DB", err, 500)
+		return
+	}
+	if deleted == 0 {
+		err := errors.New("notification mute not found")
+		ErrorResponseWriter(res, "notification type is not muted", err, 404)
+		return
+	}
+	res.WriteHeader(204)
+}
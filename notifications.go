@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+)
+
+// notification types, also what users mute by
+const (
+	notifyPollVoted      = "poll.voted"
+	notifyChirpLiked     = "chirp.liked"
+	notifyChirpReplied   = "chirp.replied"
+	notifyChirpMentioned = "chirp.mentioned"
+	notifyChirpPublished = "chirp.published"
+	notifyChirpRejected  = "chirp.rejected"
+	notifyUserFollowed   = "user.followed"
+	notifyUserUpgraded   = "user.upgraded"
+	notifyUserDowngraded = "user.downgraded"
+)
+
+var notificationTypes = []string{
+	notifyPollVoted, notifyChirpLiked, notifyChirpReplied, notifyChirpMentioned,
+	notifyChirpPublished, notifyChirpRejected, notifyUserFollowed, notifyUserUpgraded, notifyUserDowngraded,
+}
+
+// @handle anywhere but the middle of a word or an email address
+var mentionRegex = regexp.MustCompile(`(?:^|[^a-zA-Z0-9_@])@([a-zA-Z0-9_]{3,30})\b`)
+
+type Notification struct {
+	ID         uuid.UUID   `json:"id"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+	UserID     uuid.UUID   `json:"-"`
+	Type       string      `json:"type"`
+	Message    string      `json:"message"`
+	ChirpID    *uuid.UUID  `json:"chirp_id"`
+	ActorIDs   []uuid.UUID `json:"actor_ids"`
+	EventCount int32       `json:"event_count"`
+	ReadAt     *time.Time  `json:"read_at"`
+}
+
+func notificationFromDB(dbNotification database.Notification) Notification {
+	notification := Notification{
+		ID:         dbNotification.ID,
+		CreatedAt:  dbNotification.CreatedAt,
+		UpdatedAt:  dbNotification.UpdatedAt,
+		UserID:     dbNotification.UserID,
+		Type:       dbNotification.Type,
+		Message:    notificationMessage(dbNotification),
+		ChirpID:    nullUUIDPtr(dbNotification.ChirpID),
+		ActorIDs:   dbNotification.ActorIds,
+		EventCount: dbNotification.EventCount,
+		ReadAt:     nullTimePtr(dbNotification.ReadAt),
+	}
+	if notification.ActorIDs == nil {
+		notification.ActorIDs = []uuid.UUID{}
+	}
+	return notification
+}
+
+// built when read rather than stored, so a grouped notification always shows its current count
+func notificationMessage(dbNotification database.Notification) string {
+	people := "1 person"
+	if len(dbNotification.ActorIds) != 1 {
+		people = fmt.Sprintf("%d people", len(dbNotification.ActorIds))
+	}
+	switch dbNotification.Type {
+	case notifyPollVoted:
+		return people + " voted in your poll"
+	case notifyChirpLiked:
+		return people + " liked your chirp"
+	case notifyChirpReplied:
+		return people + " replied to your chirp"
+	case notifyChirpMentioned:
+		return people + " mentioned you in a chirp"
+	case notifyChirpPublished:
+		return "Your scheduled chirp was published"
+	case notifyChirpRejected:
+		if dbNotification.EventCount > 1 {
+			return fmt.Sprintf("%d of your scheduled chirps weren't published, they contain content that isn't allowed", dbNotification.EventCount)
+		}
+		return "Your scheduled chirp wasn't published, it contains content that isn't allowed"
+	case notifyUserFollowed:
+		return people + " followed you"
+	case notifyUserUpgraded:
+		return "Your account was upgraded to Chirpy Red"
+	case notifyUserDowngraded:
+		return "Your Chirpy Red subscription has ended"
+	}
+	return dbNotification.Type
+}
+
+// generator hook for every notification. events about the same chirp fold into one unread
+// notification ("5 people voted in your poll"), muted types and blocked actors are dropped by the query itself.
+// pass the qtx of the transaction making the change where there is one
+func notifyUser(ctx context.Context, qtx *database.Queries, userID uuid.UUID, notificationType string, chirpID, actorID uuid.NullUUID) error {
+	//nobody needs to hear about their own actions
+	if actorID.Valid && actorID.UUID == userID {
+		return nil
+	}
+	groupKey := notificationType
+	if chirpID.Valid {
+		groupKey += ":" + chirpID.UUID.String()
+	}
+	actorIDs := []uuid.UUID{}
+	if actorID.Valid {
+		actorIDs = append(actorIDs, actorID.UUID)
+	}
+	return qtx.UpsertNotification(ctx, database.UpsertNotificationParams{
+		UserID:   userID,
+		Type:     notificationType,
+		GroupKey: groupKey,
+		ChirpID:  chirpID,
+		ActorIds: actorIDs,
+	})
+}
+
+// the distinct handles @mentioned in a chirp body, lowercased like the stored ones
+func mentionedHandles(body string) []string {
+	handles := []string{}
+	seen := map[string]bool{}
+	for _, match := range mentionRegex.FindAllStringSubmatch(body, -1) {
+		handle := strings.ToLower(match[1])
+		if !seen[handle] {
+			seen[handle] = true
+			handles = append(handles, handle)
+		}
+	}
+	return handles
+}
+
+// tells everyone @mentioned in a newly published chirp. skipID is the reply target's author,
+// who already hears about the chirp as a reply. shadow limited chirps don't notify anybody
+func notifyMentions(ctx context.Context, qtx *database.Queries, dbChirp database.Chirp, skipID uuid.NullUUID) error {
+	if dbChirp.ShadowLimitedAt.Valid {
+		return nil
+	}
+	handles := mentionedHandles(dbChirp.Body)
+	if len(handles) == 0 {
+		return nil
+	}
+	mentionedIDs, err := qtx.GetUserIDsByHandles(ctx, handles)
+	if err != nil {
+		return err
+	}
+	for _, mentionedID := range mentionedIDs {
+		if skipID.Valid && mentionedID == skipID.UUID {
+			continue
+		}
+		if err := notifyUser(ctx, qtx, mentionedID, notifyChirpMentioned,
+			uuid.NullUUID{UUID: dbChirp.ID, Valid: true}, uuid.NullUUID{UUID: dbChirp.UserID, Valid: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tells the author of the chirp dbChirp replies to, for replies that weren't announced when posted.
+// returns who was told so notifyMentions can skip them
+func notifyReplyTarget(ctx context.Context, qtx *database.Queries, dbChirp database.Chirp) (uuid.NullUUID, error) {
+	if !dbChirp.ReplyToID.Valid {
+		return uuid.NullUUID{}, nil
+	}
+	dbTarget, err := qtx.GetOneChirp(ctx, dbChirp.ReplyToID.UUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.NullUUID{}, nil
+	}
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	if dbTarget.HiddenAt.Valid {
+		return uuid.NullUUID{}, nil
+	}
+	targetAuthorID := uuid.NullUUID{UUID: dbTarget.UserID, Valid: true}
+	return targetAuthorID, notifyUser(ctx, qtx, dbTarget.UserID, notifyChirpReplied,
+		dbChirp.ReplyToID, uuid.NullUUID{UUID: dbChirp.UserID, Valid: true})
+}
+
+// which notification, if any, a polka event turns into
+func polkaNotificationType(event string) string {
+	switch event {
+	case polkaUserUpgraded:
+		return notifyUserUpgraded
+	case polkaUserDowngraded, polkaRefundIssued:
+		return notifyUserDowngraded
+	}
+	return ""
+}
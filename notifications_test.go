@@ -0,0 +1,44 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestMentionedHandles(t *testing.T) {
+	//test1 - mentions anywhere in the body, lowercased and deduplicated
+	handles := mentionedHandles("@Alice hi, cc @bob_99 and @alice again")
+	if !slices.Equal(handles, []string{"alice", "bob_99"}) {
+		t.Fatalf("test-FAIL: expected [alice bob_99], got %v", handles)
+	}
+	t.Logf("test-PASS: mentions are found, lowercased and deduplicated")
+
+	//test2 - emails, too-short and too-long handles aren't mentions
+	handles = mentionedHandles("mail me@example.com, @ab, @" + "abcdefghijklmnopqrstuvwxyz12345")
+	if len(handles) != 0 {
+		t.Fatalf("test-FAIL: expected no mentions, got %v", handles)
+	}
+	t.Logf("test-PASS: emails and out-of-range handles are ignored")
+}
+
+func TestNotificationMessageGrouping(t *testing.T) {
+	liked := database.Notification{Type: notifyChirpLiked, ActorIds: []uuid.UUID{uuid.New()}}
+
+	//test1 - a single actor
+	if msg := notificationMessage(liked); msg != "1 person liked your chirp" {
+		t.Fatalf("test-FAIL: unexpected message %q", msg)
+	}
+	t.Logf("test-PASS: one like reads as 1 person")
+
+	//test2 - grouped actors
+	for range 4 {
+		liked.ActorIds = append(liked.ActorIds, uuid.New())
+	}
+	if msg := notificationMessage(liked); msg != "5 people liked your chirp" {
+		t.Fatalf("test-FAIL: unexpected message %q", msg)
+	}
+	t.Logf("test-PASS: five likes read as 5 people")
+}
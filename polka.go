@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+)
+
+// how far a webhook's timestamp may drift from our clock before it's treated as a replay
+const polkaSignatureTolerance = 5 * time.Minute
+
+// polka webhook event types we act on, anything else is recorded as ignored
+const (
+	polkaUserUpgraded         = "user.upgraded"
+	polkaUserDowngraded       = "user.downgraded"
+	polkaSubscriptionRenewed  = "subscription.renewed"
+	polkaPaymentFailed        = "payment.failed"
+	polkaRefundIssued         = "refund.issued"
+	polkaResultIgnored        = "ignored"
+	polkaResultNoSubscription = "no_subscription"
+)
+
+// which outgoing webhook, if any, a polka event turns into
+func polkaWebhookEvent(event string) string {
+	switch event {
+	case polkaUserUpgraded:
+		return webhookUserUpgraded
+	case polkaUserDowngraded, polkaRefundIssued:
+		return webhookUserDowngraded
+	}
+	return ""
+}
+
+func isKnownPolkaEvent(event string) bool {
+	switch event {
+	case polkaUserUpgraded, polkaUserDowngraded, polkaSubscriptionRenewed, polkaPaymentFailed, polkaRefundIssued:
+		return true
+	}
+	return false
+}
+
+// updates the user's chirpy red subscription for one polka event and returns what happened,
+// which gets stored in polka_events. runs inside the webhook's transaction
+func applyPolkaEvent(ctx context.Context, qtx *database.Queries, event string, userID uuid.UUID) (string, error) {
+	result := ""
+	switch event {
+	case polkaUserUpgraded:
+		if _, err := qtx.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+			UserID:           userID,
+			Plan:             chirpyRedPlan,
+			Status:           "active",
+			CurrentPeriodEnd: time.Now().UTC().Add(chirpyRedPeriod),
+			Source:           "polka",
+		}); err != nil {
+			return "", err
+		}
+		result = "active"
+
+	case polkaSubscriptionRenewed:
+		//a renewal stacks on whatever is left of the current period
+		periodStart := time.Now().UTC()
+		currentSub, err := qtx.GetSubscription(ctx,
+			database.GetSubscriptionParams{UserID: userID, Plan: chirpyRedPlan})
+		if err == nil && currentSub.Status == "active" && currentSub.CurrentPeriodEnd.After(periodStart) {
+			periodStart = currentSub.CurrentPeriodEnd
+		}
+		if _, err := qtx.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+			UserID:           userID,
+			Plan:             chirpyRedPlan,
+			Status:           "active",
+			CurrentPeriodEnd: periodStart.Add(chirpyRedPeriod),
+			Source:           "polka",
+		}); err != nil {
+			return "", err
+		}
+		result = "active"
+
+	case polkaPaymentFailed, polkaUserDowngraded, polkaRefundIssued:
+		//past_due keeps access until current_period_end, canceled and refunded lose it right away
+		status := "past_due"
+		if event == polkaUserDowngraded {
+			status = "canceled"
+		} else if event == polkaRefundIssued {
+			status = "refunded"
+		}
+		updated, err := qtx.SetSubscriptionStatus(ctx, database.SetSubscriptionStatusParams{
+			Status: status,
+			UserID: userID,
+			Plan:   chirpyRedPlan,
+		})
+		if err != nil {
+			return "", err
+		}
+		result = status
+		if updated == 0 {
+			result = polkaResultNoSubscription
+		}
+
+	default:
+		return polkaResultIgnored, nil
+	}
+
+	if err := qtx.SyncUserChirpyRed(ctx, userID); err != nil {
+		return "", err
+	}
+	return result, nil
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 4
+	maxPollOptionLength = 50
+	minPollDuration     = 5 * time.Minute
+	maxPollDuration     = 24 * time.Hour
+	maxRedPollDuration  = 7 * 24 * time.Hour
+)
+
+type Poll struct {
+	ID             uuid.UUID    `json:"id"`
+	AllowsMultiple bool         `json:"allows_multiple"`
+	ExpiresAt      time.Time    `json:"expires_at"`
+	Expired        bool         `json:"expired"`
+	TotalVotes     int64        `json:"total_votes"`
+	Options        []PollOption `json:"options"`
+	MyVotes        []uuid.UUID  `json:"my_votes,omitempty"`
+	UpdatedAt      time.Time    `json:"-"`
+}
+type PollOption struct {
+	ID    uuid.UUID `json:"id"`
+	Text  string    `json:"text"`
+	Votes int64     `json:"votes"`
+}
+
+// the "poll" object inside a POST /api/chirps body
+type pollRequest struct {
+	Options        []string `json:"options"`
+	ExpiresAt      string   `json:"expires_at"`
+	AllowsMultiple bool     `json:"allows_multiple"`
+}
+
+// checks the poll part of a new chirp and returns the parsed expiry. maxDuration comes from the
+// author's entitlements, polls longer than a day are a chirpy red perk
+func validatePollRequest(pollReq *pollRequest, maxDuration time.Duration) (time.Time, error) {
+	if len(pollReq.Options) < minPollOptions || len(pollReq.Options) > maxPollOptions {
+		return time.Time{}, errors.New("polls need between 2 and 4 options")
+	}
+	for i, option := range pollReq.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || len(option) > maxPollOptionLength {
+			return time.Time{}, errors.New("poll options must be between 1 and 50 characters")
+		}
+		pollReq.Options[i] = option
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, pollReq.ExpiresAt)
+	if err != nil {
+		return time.Time{}, errors.New("poll expires_at must be an RFC3339 timestamp")
+	}
+	duration := time.Until(expiresAt)
+	if duration < minPollDuration {
+		return time.Time{}, errors.New("polls must stay open for at least 5 minutes")
+	}
+	if duration > maxDuration {
+		if maxDuration < maxRedPollDuration {
+			return time.Time{}, errors.New("polls longer than 24 hours require chirpy red")
+		}
+		return time.Time{}, errors.New("polls can stay open for at most 7 days")
+	}
+	return expiresAt.UTC(), nil
+}
+
+// writes the poll and its options, meant to run in the same transaction as CreateChirp
+func createPoll(ctx context.Context, qtx *database.Queries, chirpID uuid.UUID, pollReq *pollRequest, expiresAt time.Time) error {
+	dbPoll, err := qtx.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:        chirpID,
+		AllowsMultiple: pollReq.AllowsMultiple,
+		ExpiresAt:      expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	for i, option := range pollReq.Options {
+		if _, err := qtx.CreatePollOption(ctx, database.CreatePollOptionParams{
+			PollID:   dbPoll.ID,
+			Position: int32(i),
+			Text:     option,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fills in Chirp.Poll for every chirp that has one, using three queries no matter how many chirps there are.
+// viewerID is optional, when set the viewer's own votes come back in my_votes
+func (cfg *apiConfig) attachPolls(ctx context.Context, chirps []Chirp, viewerID uuid.NullUUID) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+	dbPolls, err := cfg.DB.GetPollsForChirps(ctx, chirpIDs)
+	if err != nil {
+		return err
+	}
+	if len(dbPolls) == 0 {
+		return nil
+	}
+
+	pollsByID := map[uuid.UUID]*Poll{}
+	pollsByChirp := map[uuid.UUID]*Poll{}
+	pollIDs := make([]uuid.UUID, 0, len(dbPolls))
+	for _, dbPoll := range dbPolls {
+		poll := &Poll{
+			ID:             dbPoll.ID,
+			AllowsMultiple: dbPoll.AllowsMultiple,
+			ExpiresAt:      dbPoll.ExpiresAt,
+			Expired:        !time.Now().Before(dbPoll.ExpiresAt),
+			Options:        []PollOption{},
+			UpdatedAt:      dbPoll.UpdatedAt,
+		}
+		pollsByID[dbPoll.ID] = poll
+		pollsByChirp[dbPoll.ChirpID] = poll
+		pollIDs = append(pollIDs, dbPoll.ID)
+	}
+
+	tallies, err := cfg.DB.GetPollTallies(ctx, pollIDs)
+	if err != nil {
+		return err
+	}
+	for _, row := range tallies {
+		poll := pollsByID[row.PollID]
+		poll.Options = append(poll.Options, PollOption{ID: row.ID, Text: row.Text, Votes: row.Votes})
+		poll.TotalVotes += row.Votes
+	}
+
+	if viewerID.Valid {
+		myVotes, err := cfg.DB.GetUsersPollVotes(ctx,
+			database.GetUsersPollVotesParams{UserID: viewerID.UUID, PollIds: pollIDs})
+		if err != nil {
+			return err
+		}
+		for _, row := range myVotes {
+			poll := pollsByID[row.PollID]
+			poll.MyVotes = append(poll.MyVotes, row.OptionID)
+		}
+	}
+
+	for i := range chirps {
+		chirps[i].Poll = pollsByChirp[chirps[i].ID]
+	}
+	return nil
+}
+
+// reads requests that may or may not be logged in. a missing or bad token just means anonymous
+func (cfg *apiConfig) getOptionalUserID(req *http.Request) uuid.NullUUID {
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}
+}
+
+func (cfg *apiConfig) VotePoll(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	reqChirpId, err := uuid.Parse(req.PathValue("chirpId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+
+	type VoteRequest struct {
+		OptionID  string   `json:"option_id"`
+		OptionIDs []string `json:"option_ids"`
+	}
+	reqData, err := io.ReadAll(req.Body)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to read request body", err, 500)
+		return
+	}
+	var voteReq VoteRequest
+	if err := json.Unmarshal(reqData, &voteReq); err != nil {
+		ErrorResponseWriter(res, "Failed to decode request body", err, 400)
+		return
+	}
+	rawOptionIDs := voteReq.OptionIDs
+	if voteReq.OptionID != "" {
+		rawOptionIDs = append(rawOptionIDs, voteReq.OptionID)
+	}
+	if len(rawOptionIDs) == 0 {
+		err := errors.New("missing option_id or option_ids field")
+		ErrorResponseWriter(res, "request body did not contain any options to vote for", err, 400)
+		return
+	}
+	optionIDs := map[uuid.UUID]bool{}
+	for _, rawOptionID := range rawOptionIDs {
+		optionID, err := uuid.Parse(rawOptionID)
+		if err != nil {
+			ErrorResponseWriter(res, "failed to parse option id", err, 400)
+			return
+		}
+		optionIDs[optionID] = true
+	}
+
+	tx, err := cfg.DBConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to start DB transaction", err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	//locking the poll row serializes votes, so two quick taps can't both count on a single-choice poll
+	dbPoll, err := qtx.GetPollForUpdate(req.Context(), reqChirpId)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to find a poll on the chirp with provided id", err, 404)
+		return
+	}
+	if !time.Now().Before(dbPoll.ExpiresAt) {
+		err := errors.New("poll has expired")
+		ErrorResponseWriter(res, "voting on this poll has closed", err, 403)
+		return
+	}
+
+	tallies, err := qtx.GetPollTallies(req.Context(), []uuid.UUID{dbPoll.ID})
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for poll options in DB", err, 500)
+		return
+	}
+	validOptions := map[uuid.UUID]bool{}
+	for _, row := range tallies {
+		validOptions[row.ID] = true
+	}
+	for optionID := range optionIDs {
+		if !validOptions[optionID] {
+			err := errors.New("option does not belong to this poll")
+			ErrorResponseWriter(res, "invalid poll option", err, 400)
+			return
+		}
+	}
+
+	if !dbPoll.AllowsMultiple {
+		if len(optionIDs) > 1 {
+			err := errors.New("poll only allows one choice")
+			ErrorResponseWriter(res, "this poll only allows a single vote", err, 400)
+			return
+		}
+		existingVotes, err := qtx.GetUsersPollVotes(req.Context(),
+			database.GetUsersPollVotesParams{UserID: userID, PollIds: []uuid.UUID{dbPoll.ID}})
+		if err != nil {
+			ErrorResponseWriter(res, "failed to query for existing votes in DB", err, 500)
+			return
+		}
+		if len(existingVotes) > 0 {
+			err := errors.New("user already voted")
+			ErrorResponseWriter(res, "you have already voted on this poll", err, 409)
+			return
+		}
+	}
+
+	for optionID := range optionIDs {
+		if err := qtx.CreatePollVote(req.Context(), database.CreatePollVoteParams{
+			PollID:   dbPoll.ID,
+			OptionID: optionID,
+			UserID:   userID,
+		}); err != nil {
+			ErrorResponseWriter(res, "Failed to write vote to DB", err, 500)
+			return
+		}
+	}
+	if err := qtx.TouchPoll(req.Context(), dbPoll.ID); err != nil {
+		ErrorResponseWriter(res, "Failed to update poll in DB", err, 500)
+		return
+	}
+	dbChirp, err := qtx.GetOneChirp(req.Context(), reqChirpId)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to find chirp with provided id in DB", err, 500)
+		return
+	}
+	if err := notifyUser(req.Context(), qtx, dbChirp.UserID, notifyPollVoted,
+		uuid.NullUUID{UUID: dbChirp.ID, Valid: true}, uuid.NullUUID{UUID: userID, Valid: true}); err != nil {
+		ErrorResponseWriter(res, "Failed to write notification to DB", err, 500)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		ErrorResponseWriter(res, "Failed to commit DB transaction", err, 500)
+		return
+	}
+
+	votedChirp := []Chirp{{ID: reqChirpId}}
+	if err := cfg.attachPolls(req.Context(), votedChirp, uuid.NullUUID{UUID: userID, Valid: true}); err != nil {
+		ErrorResponseWriter(res, "failed to query for poll results in DB", err, 500)
+		return
+	}
+	successRes, err := json.Marshal(votedChirp[0].Poll)
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/apierror"
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/JettMingin/chirpy-bootdev/internal/logging"
+	"github.com/JettMingin/chirpy-bootdev/internal/ratelimit"
+)
+
+// values for RATE_LIMIT_BACKEND. postgres shares the buckets between instances
+const (
+	rateLimitMemory   = "memory"
+	rateLimitPostgres = "postgres"
+	rateLimitNone     = "none"
+)
+
+// RATE_LIMIT_READ and RATE_LIMIT_WRITE when they aren't set, per user or per ip
+const (
+	defaultReadLimit  = "300/1m"
+	defaultWriteLimit = "30/1m"
+)
+
+// health checks and Polka's webhooks are never limited. metric scrapes are, so the
+// token can't be guessed at full speed, a scraper stays far below the read limit anyway
+var rateLimitExempt = map[string]bool{
+	"/api/healthz":        true,
+	"/api/polka/webhooks": true,
+}
+
+// reads (GET and HEAD) and writes (everything else, so postChirp, postUser, Login...) have
+// separate buckets. Chirpy Red users get both limits times their RateLimitMultiplier
+type rateLimiter struct {
+	Store ratelimit.Store
+	Read  ratelimit.Limit
+	Write ratelimit.Limit
+}
+
+// nil when the backend is none, empty limits fall back to the defaults above
+func newRateLimiter(backend, rawRead, rawWrite string, db *database.Queries) (*rateLimiter, error) {
+	var store ratelimit.Store
+	switch backend {
+	case rateLimitMemory, "":
+		store = ratelimit.NewMemoryStore()
+	case rateLimitPostgres:
+		store = ratelimit.NewPostgresStore(db)
+	case rateLimitNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_BACKEND %q, expected memory, postgres or none", backend)
+	}
+	if rawRead == "" {
+		rawRead = defaultReadLimit
+	}
+	if rawWrite == "" {
+		rawWrite = defaultWriteLimit
+	}
+	readLimit, err := ratelimit.ParseLimit(rawRead)
+	if err != nil {
+		return nil, err
+	}
+	writeLimit, err := ratelimit.ParseLimit(rawWrite)
+	if err != nil {
+		return nil, err
+	}
+	return &rateLimiter{Store: store, Read: readLimit, Write: writeLimit}, nil
+}
+
+// charges every request to the signed in user, or to the client ip when there's no valid token.
+// a limiter that can't reach its store lets requests through rather than failing them all
+func (cfg *apiConfig) middlewareRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if cfg.RateLimiter == nil || rateLimitExempt[req.URL.Path] {
+			next.ServeHTTP(res, req)
+			return
+		}
+		logger := logging.FromContext(req.Context())
+
+		class, limit := "read", cfg.RateLimiter.Read
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			class, limit = "write", cfg.RateLimiter.Write
+		}
+		subject := "ip:" + cfg.clientIP(req)
+		if userID, err := cfg.getAuthedUserID(req); err == nil {
+			subject = "user:" + userID.String()
+			entitlements, err := cfg.getEntitlements(req.Context(), userID)
+			if err != nil {
+				logger.Warn("looking up entitlements for rate limit, using the free limits", "error", err)
+			} else {
+				limit = limit.Scale(entitlements.RateLimitMultiplier)
+			}
+		}
+
+		result, err := cfg.RateLimiter.Store.Take(req.Context(), class+":"+subject, limit)
+		if err != nil {
+			logger.Error("rate limiter unavailable, letting the request through", "error", err)
+			next.ServeHTTP(res, req)
+			return
+		}
+		ratelimit.SetHeaders(res.Header(), limit, result)
+		if !result.Allowed {
+			err := fmt.Errorf("%s limit of %d per %s used up by %s", class, limit.Burst, limit.Period, subject)
+			writeAPIError(res, req, apierror.New(http.StatusTooManyRequests, apierror.CodeRateLimited,
+				fmt.Sprintf("too many requests, retry in %s seconds", res.Header().Get("Retry-After")), err))
+			return
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
+// background job started from main, drops buckets nobody has used for a full period
+func (cfg *apiConfig) runRateLimitPruner(ctx context.Context, interval time.Duration) {
+	idle := max(cfg.RateLimiter.Read.Period, cfg.RateLimiter.Write.Period)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cfg.RateLimiter.Store.Prune(ctx, idle); err != nil {
+				slog.Error("pruning rate limit buckets", "error", err)
+			}
+		}
+	}
+}
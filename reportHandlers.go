@@ -0,0 +1,194 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxReportDetailsLength = 1000
+
+var reportReasons = []string{"spam", "harassment", "hate", "violence", "self_harm", "sexual", "misinformation", "impersonation", "other"}
+
+// report statuses, a report moves open -> claimed -> resolved (or straight from open to resolved)
+const (
+	reportOpen     = "open"
+	reportClaimed  = "claimed"
+	reportResolved = "resolved"
+)
+
+type Report struct {
+	ID           uuid.UUID  `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	ReporterID   *uuid.UUID `json:"reporter_id"`
+	ChirpID      *uuid.UUID `json:"chirp_id"`
+	ChirpBody    *string    `json:"chirp_body"`
+	TargetUserID *uuid.UUID `json:"target_user_id"`
+	Reason       string     `json:"reason"`
+	Details      string     `json:"details"`
+	Status       string     `json:"status"`
+	ClaimedBy    *uuid.UUID `json:"claimed_by"`
+	ClaimedAt    *time.Time `json:"claimed_at"`
+	ResolvedBy   *uuid.UUID `json:"resolved_by"`
+	ResolvedAt   *time.Time `json:"resolved_at"`
+	Resolution   *string    `json:"resolution"`
+}
+
+func reportFromDB(dbReport database.Report) Report {
+	return Report{
+		ID:           dbReport.ID,
+		CreatedAt:    dbReport.CreatedAt,
+		UpdatedAt:    dbReport.UpdatedAt,
+		ReporterID:   nullUUIDPtr(dbReport.ReporterID),
+		ChirpID:      nullUUIDPtr(dbReport.ChirpID),
+		ChirpBody:    nullStringPtr(dbReport.ChirpBody),
+		TargetUserID: nullUUIDPtr(dbReport.TargetUserID),
+		Reason:       dbReport.Reason,
+		Details:      dbReport.Details,
+		Status:       dbReport.Status,
+		ClaimedBy:    nullUUIDPtr(dbReport.ClaimedBy),
+		ClaimedAt:    nullTimePtr(dbReport.ClaimedAt),
+		ResolvedBy:   nullUUIDPtr(dbReport.ResolvedBy),
+		ResolvedAt:   nullTimePtr(dbReport.ResolvedAt),
+		Resolution:   nullStringPtr(dbReport.Resolution),
+	}
+}
+
+func nullStringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+// decodes and validates {reason, details}, writing the error response itself when the body is unusable
+func readReportRequest(res http.ResponseWriter, req *http.Request) (string, string, bool) {
+	type ReportRequest struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+	reqData, err := io.ReadAll(req.Body)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to read request body", err, 500)
+		return "", "", false
+	}
+	var reportReq ReportRequest
+	if err := json.Unmarshal(reqData, &reportReq); err != nil {
+		ErrorResponseWriter(res, "Failed to decode request body", err, 400)
+		return "", "", false
+	}
+	if !slices.Contains(reportReasons, reportReq.Reason) {
+		err := errors.New("unknown report reason")
+		ErrorResponseWriter(res, "invalid reason field", err, 400)
+		return "", "", false
+	}
+	if len(reportReq.Details) > maxReportDetailsLength {
+		err := errors.New("details too long")
+		ErrorResponseWriter(res, "details must be 1000 characters or fewer", err, 400)
+		return "", "", false
+	}
+	return reportReq.Reason, reportReq.Details, true
+}
+
+// CreateReport does nothing on conflict, so no row back means this reporter already has an open report here
+func writeCreatedReport(res http.ResponseWriter, dbReport database.Report, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		ErrorResponseWriter(res, "you already have an open report for this", err, 409)
+		return
+	}
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to write report to DB", err, 500)
+		return
+	}
+
+	successRes, err := json.Marshal(reportFromDB(dbReport))
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(201)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) ReportChirp(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	reqChirpId, err := uuid.Parse(req.PathValue("chirpId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+	dbChirp, err := cfg.DB.GetOneChirp(req.Context(), reqChirpId)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to find chirp with provided id in DB", err, 404)
+		return
+	}
+	if dbChirp.UserID == userID {
+		err := errors.New("chirp belongs to the requesting user")
+		ErrorResponseWriter(res, "you can't report your own chirp", err, 400)
+		return
+	}
+	reason, details, ok := readReportRequest(res, req)
+	if !ok {
+		return
+	}
+
+	dbReport, err := cfg.DB.CreateReport(req.Context(), database.CreateReportParams{
+		ReporterID:   uuid.NullUUID{UUID: userID, Valid: true},
+		ChirpID:      uuid.NullUUID{UUID: dbChirp.ID, Valid: true},
+		ChirpBody:    sql.NullString{String: dbChirp.Body, Valid: true},
+		TargetUserID: uuid.NullUUID{UUID: dbChirp.UserID, Valid: true},
+		Reason:       reason,
+		Details:      details,
+	})
+	writeCreatedReport(res, dbReport, err)
+}
+
+func (cfg *apiConfig) ReportUser(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	targetID, err := uuid.Parse(req.PathValue("userId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+	if targetID == userID {
+		err := errors.New("target is the requesting user")
+		ErrorResponseWriter(res, "you can't report yourself", err, 400)
+		return
+	}
+	if _, err := cfg.DB.GetUser(req.Context(), targetID); err != nil {
+		ErrorResponseWriter(res, "failed to find user with provided id in DB", err, 404)
+		return
+	}
+	reason, details, ok := readReportRequest(res, req)
+	if !ok {
+		return
+	}
+
+	dbReport, err := cfg.DB.CreateReport(req.Context(), database.CreateReportParams{
+		ReporterID:   uuid.NullUUID{UUID: userID, Valid: true},
+		TargetUserID: uuid.NullUUID{UUID: targetID, Valid: true},
+		Reason:       reason,
+		Details:      details,
+	})
+	writeCreatedReport(res, dbReport, err)
+}
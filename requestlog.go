@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/dbmetrics"
+	"github.com/JettMingin/chirpy-bootdev/internal/logging"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// statements slower than this are logged at warn, the rest only at debug
+const slowQueryThreshold = 250 * time.Millisecond
+
+// LOG_LEVEL is debug, info, warn or error. anything else means info
+func newLogger(rawLevel string) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(rawLevel)); err != nil {
+		level = slog.LevelInfo
+	}
+	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+}
+
+// second in middlewareChain, just inside tracing so its log lines carry the trace id. gives every
+// request an id, echoes it back in X-Request-ID, puts a logger tagged with it on the context,
+// and writes one log line once the response is done
+func (cfg *apiConfig) middlewareRequestLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		requestID := logging.RequestID(req.Header.Get(logging.RequestIDHeader))
+		res.Header().Set(logging.RequestIDHeader, requestID)
+
+		logger := slog.Default().With("request_id", requestID)
+		if spanCtx := trace.SpanContextFromContext(req.Context()); spanCtx.IsValid() {
+			logger = logger.With("trace_id", spanCtx.TraceID().String())
+		}
+		userID, authErr := cfg.getAuthedUserID(req)
+		if authErr == nil {
+			logger = logger.With("user_id", userID.String())
+		}
+		loggedReq := req.WithContext(logging.WithLogger(req.Context(), logger))
+		writer := &statusWriter{ResponseWriter: res, status: 200}
+		next.ServeHTTP(writer, loggedReq)
+		//the mux set Pattern on loggedReq, hand it back out to the tracing middleware's span
+		req.Pattern = loggedReq.Pattern
+
+		level := slog.LevelInfo
+		if writer.status >= 500 {
+			level = slog.LevelError
+		} else if writer.status >= 400 {
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("method", req.Method),
+			slog.String("route", loggedReq.Pattern),
+			slog.String("path", req.URL.Path),
+			slog.Int("status", writer.status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int64("bytes", writer.bytes),
+		}
+		if writer.err != nil {
+			attrs = append(attrs, slog.String("error", writer.err.Error()), slog.String("error_message", writer.errMsg))
+		}
+		logger.LogAttrs(req.Context(), level, "request", attrs...)
+	})
+}
+
+// dbmetrics.Observer, logs statements with the request's logger when there is one
+func logQuery(ctx context.Context, query string, duration time.Duration, err error) {
+	level := slog.LevelDebug
+	if err != nil || duration >= slowQueryThreshold {
+		level = slog.LevelWarn
+	}
+	logger := logging.FromContext(ctx)
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("query", dbmetrics.QueryName(query)),
+		slog.Float64("duration_ms", float64(duration.Microseconds())/1000),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logger.LogAttrs(ctx, level, "db query", attrs...)
+}
+
+// hands an error response's details to every statusWriter wrapped around res,
+// so the request log line can say why a request failed
+func noteResponseError(res http.ResponseWriter, errMsg string, err error) {
+	for res != nil {
+		if writer, ok := res.(*statusWriter); ok {
+			writer.errMsg = errMsg
+			writer.err = err
+		}
+		unwrapper, ok := res.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		res = unwrapper.Unwrap()
+	}
+}
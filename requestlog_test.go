@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestMiddlewareChainRoute(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	cfg := &apiConfig{Metrics: newChirpyMetrics(), TracerProvider: provider}
+	servemux := http.NewServeMux()
+	servemux.HandleFunc("GET /api/chirps/{chirpId}", func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(200)
+	})
+	handler := cfg.middlewareChain(servemux)
+
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	defer slog.SetDefault(defaultLogger)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/chirps/123", nil))
+
+	//test1 - the span gets the route the mux matched, through every middleware in between
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "GET /api/chirps/{chirpId}" {
+		t.Fatalf("test-FAIL: expected one span named after the route, got %+v", spans)
+	}
+	route := ""
+	for _, attr := range spans[0].Attributes {
+		if attr.Key == "http.route" {
+			route = attr.Value.AsString()
+		}
+	}
+	if route != "GET /api/chirps/{chirpId}" {
+		t.Fatalf("test-FAIL: expected an http.route attribute, got %v", spans[0].Attributes)
+	} else {
+		t.Logf("test-PASS: span %s", spans[0].Name)
+	}
+
+	//test2 - the request log line and the request metrics carry it too
+	var line struct {
+		Route string `json:"route"`
+	}
+	if err := json.Unmarshal(logs.Bytes(), &line); err != nil || line.Route != "GET /api/chirps/{chirpId}" {
+		t.Fatalf("test-FAIL: expected the route in the request log, got %s", logs.String())
+	}
+	if count := testutil.ToFloat64(cfg.Metrics.httpRequests.WithLabelValues("GET /api/chirps/{chirpId}", "GET", "200")); count != 1 {
+		t.Fatalf("test-FAIL: expected 1 request counted under the route, got %v", count)
+	} else {
+		t.Logf("test-PASS: logged and counted as %s", line.Route)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+)
+
+// how many due chirps one worker tick moves into the chirps table per transaction
+const scheduledChirpBatchSize = 50
+
+type ScheduledChirp struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+	UserID    uuid.UUID `json:"user_id"`
+	PublishAt time.Time `json:"publish_at"`
+	Locale    string    `json:"locale"`
+}
+
+func scheduledChirpFromDB(dbScheduled database.ScheduledChirp) ScheduledChirp {
+	return ScheduledChirp{
+		ID:        dbScheduled.ID,
+		CreatedAt: dbScheduled.CreatedAt,
+		UpdatedAt: dbScheduled.UpdatedAt,
+		Body:      dbScheduled.Body,
+		UserID:    dbScheduled.UserID,
+		PublishAt: dbScheduled.PublishAt,
+		Locale:    dbScheduled.Locale,
+	}
+}
+
+// publish_at has to be RFC3339 and in the future
+func parsePublishAt(rawPublishAt string) (time.Time, error) {
+	publishAt, err := time.Parse(time.RFC3339, rawPublishAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !publishAt.After(time.Now()) {
+		return time.Time{}, errors.New("publish_at is not in the future")
+	}
+	return publishAt.UTC(), nil
+}
+
+// called from postChirp once the body is validated and a publish_at was sent.
+// the locale is kept so the worker checks the chirp against the same rules postChirp did
+func (cfg *apiConfig) scheduleChirp(res http.ResponseWriter, req *http.Request, body string, userID uuid.UUID, publishAt time.Time) {
+	dbScheduled, err := cfg.DB.CreateScheduledChirp(req.Context(), database.CreateScheduledChirpParams{
+		Body:      body,
+		UserID:    userID,
+		PublishAt: publishAt,
+		Locale:    requestLocale(req),
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to write scheduled chirp to DB", err, 500)
+		return
+	}
+
+	successRes, err := json.Marshal(scheduledChirpFromDB(dbScheduled))
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(202)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) GetScheduledChirps(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	dbScheduled, err := cfg.DB.GetUsersScheduledChirps(req.Context(), userID)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for scheduled chirps in DB", err, 500)
+		return
+	}
+
+	selectedScheduled := []ScheduledChirp{}
+	for _, row := range dbScheduled {
+		selectedScheduled = append(selectedScheduled, scheduledChirpFromDB(row))
+	}
+
+	successRes, err := json.Marshal(selectedScheduled)
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) RescheduleChirp(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	scheduledID, err := uuid.Parse(req.PathValue("scheduledId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+
+	reqData, err := io.ReadAll(req.Body)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to read request body", err, 500)
+		return
+	}
+	var rescheduleReq map[string]string
+	if err := json.Unmarshal(reqData, &rescheduleReq); err != nil {
+		ErrorResponseWriter(res, "Failed to decode request body", err, 400)
+		return
+	}
+	publishAt, err := parsePublishAt(rescheduleReq["publish_at"])
+	if err != nil {
+		ErrorResponseWriter(res, "publish_at must be an RFC3339 timestamp in the future", err, 400)
+		return
+	}
+
+	//if the worker already has the row locked this waits for it, then finds nothing
+	dbScheduled, err := cfg.DB.RescheduleChirp(req.Context(), database.RescheduleChirpParams{
+		PublishAt: publishAt,
+		ID:        scheduledID,
+		UserID:    userID,
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "failed to find scheduled chirp with provided id in DB", err, 404)
+		return
+	}
+
+	successRes, err := json.Marshal(scheduledChirpFromDB(dbScheduled))
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) CancelScheduledChirp(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	scheduledID, err := uuid.Parse(req.PathValue("scheduledId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+
+	deleted, err := cfg.DB.CancelScheduledChirp(req.Context(),
+		database.CancelScheduledChirpParams{ID: scheduledID, UserID: userID})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to cancel scheduled chirp in DB", err, 500)
+		return
+	}
+	if deleted == 0 {
+		err := errors.New("scheduled chirp not found")
+		ErrorResponseWriter(res, "failed to find scheduled chirp with provided id in DB", err, 404)
+		return
+	}
+	res.WriteHeader(204)
+}
+
+// background worker started from main. every instance can run one, SKIP LOCKED keeps them
+// from publishing the same row twice
+func (cfg *apiConfig) runScheduledChirpPublisher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				published, err := cfg.publishDueChirps(ctx)
+				if err != nil {
+					slog.Error("publishing scheduled chirps", "error", err)
+					break
+				}
+				if published < scheduledChirpBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+func (cfg *apiConfig) publishDueChirps(ctx context.Context) (int, error) {
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	//suspended authors can't post, so their due chirps are dropped rather than published for them.
+	//the claim skips them as well, in case a suspension lands in between
+	if _, err := qtx.DeleteSuspendedDueScheduledChirps(ctx); err != nil {
+		return 0, err
+	}
+	dueChirps, err := qtx.ClaimDueScheduledChirps(ctx, scheduledChirpBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	published := 0
+	for _, scheduled := range dueChirps {
+		//rules may have changed since it was scheduled. a reject match is dropped rather than published,
+		//and the author is told since nobody is around to refuse it to
+		filtered := cfg.ContentFilter.Check(scheduled.Body, scheduled.Locale)
+		if filtered.Reject {
+			if err := notifyUser(ctx, qtx, scheduled.UserID, notifyChirpRejected,
+				uuid.NullUUID{}, uuid.NullUUID{}); err != nil {
+				return 0, err
+			}
+			if err := qtx.DeleteScheduledChirp(ctx, scheduled.ID); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		dbChirp, rejected, err := createScoredChirp(ctx, qtx,
+			database.CreateChirpParams{Body: filtered.Text, UserID: scheduled.UserID})
+		if err != nil {
+			return 0, err
+		}
+		//spam is dropped the same way, the decision stays in spam_decisions for review
+		if rejected {
+			if err := notifyUser(ctx, qtx, scheduled.UserID, notifyChirpRejected,
+				uuid.NullUUID{}, uuid.NullUUID{}); err != nil {
+				return 0, err
+			}
+			if err := qtx.DeleteScheduledChirp(ctx, scheduled.ID); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if filtered.Flag {
+			if err := flagChirpForReview(ctx, qtx, dbChirp, filtered); err != nil {
+				return 0, err
+			}
+		}
+		if !dbChirp.ShadowLimitedAt.Valid {
+			if err := enqueueWebhookEvent(ctx, qtx, webhookChirpCreated, chirpFromDB(dbChirp)); err != nil {
+				return 0, err
+			}
+			if err := notifyMentions(ctx, qtx, dbChirp, uuid.NullUUID{}); err != nil {
+				return 0, err
+			}
+		}
+		if err := notifyUser(ctx, qtx, dbChirp.UserID, notifyChirpPublished,
+			uuid.NullUUID{UUID: dbChirp.ID, Valid: true}, uuid.NullUUID{}); err != nil {
+			return 0, err
+		}
+		if err := qtx.DeleteScheduledChirp(ctx, scheduled.ID); err != nil {
+			return 0, err
+		}
+		published++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	cfg.Metrics.chirpsCreated.WithLabelValues(chirpSourceScheduled).Add(float64(published))
+	return len(dueChirps), nil
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/JettMingin/chirpy-bootdev/internal/moderation"
+	"github.com/google/uuid"
+)
+
+// every new chirp is scored before it's written, see createScoredChirp. shadow limited chirps are only shown to their
+// author, rejected ones are never written. every decision is kept in spam_decisions for review
+
+const moderationReviewSpam = "review_spam"
+
+type SpamDecision struct {
+	ID            uuid.UUID       `json:"id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UserID        uuid.UUID       `json:"user_id"`
+	ChirpID       *uuid.UUID      `json:"chirp_id"`
+	Body          string          `json:"body"`
+	Score         float64         `json:"score"`
+	Decision      string          `json:"decision"`
+	Features      json.RawMessage `json:"features"`
+	ReviewedBy    *uuid.UUID      `json:"reviewed_by"`
+	ReviewedAt    *time.Time      `json:"reviewed_at"`
+	FalsePositive *bool           `json:"false_positive"`
+}
+
+func spamDecisionFromDB(dbDecision database.SpamDecision) SpamDecision {
+	decision := SpamDecision{
+		ID:         dbDecision.ID,
+		CreatedAt:  dbDecision.CreatedAt,
+		UserID:     dbDecision.UserID,
+		ChirpID:    nullUUIDPtr(dbDecision.ChirpID),
+		Body:       dbDecision.Body,
+		Score:      dbDecision.Score,
+		Decision:   dbDecision.Decision,
+		Features:   dbDecision.Features,
+		ReviewedBy: nullUUIDPtr(dbDecision.ReviewedBy),
+		ReviewedAt: nullTimePtr(dbDecision.ReviewedAt),
+	}
+	if dbDecision.FalsePositive.Valid {
+		decision.FalsePositive = &dbDecision.FalsePositive.Bool
+	}
+	return decision
+}
+
+type SpamSettings struct {
+	UpdatedAt              time.Time  `json:"updated_at"`
+	UpdatedBy              *uuid.UUID `json:"updated_by"`
+	ShadowLimitScore       float64    `json:"shadow_limit_score"`
+	RejectScore            float64    `json:"reject_score"`
+	DuplicateWindowMinutes int32      `json:"duplicate_window_minutes"`
+	DuplicateWeight        float64    `json:"duplicate_weight"`
+	LinkWeight             float64    `json:"link_weight"`
+	NewAccountHours        int32      `json:"new_account_hours"`
+	NewAccountWeight       float64    `json:"new_account_weight"`
+	VelocityWindowMinutes  int32      `json:"velocity_window_minutes"`
+	VelocityLimit          int32      `json:"velocity_limit"`
+	VelocityWeight         float64    `json:"velocity_weight"`
+}
+
+func spamSettingsFromDB(dbSettings database.SpamSetting) SpamSettings {
+	return SpamSettings{
+		UpdatedAt:              dbSettings.UpdatedAt,
+		UpdatedBy:              nullUUIDPtr(dbSettings.UpdatedBy),
+		ShadowLimitScore:       dbSettings.ShadowLimitScore,
+		RejectScore:            dbSettings.RejectScore,
+		DuplicateWindowMinutes: dbSettings.DuplicateWindowMinutes,
+		DuplicateWeight:        dbSettings.DuplicateWeight,
+		LinkWeight:             dbSettings.LinkWeight,
+		NewAccountHours:        dbSettings.NewAccountHours,
+		NewAccountWeight:       dbSettings.NewAccountWeight,
+		VelocityWindowMinutes:  dbSettings.VelocityWindowMinutes,
+		VelocityLimit:          dbSettings.VelocityLimit,
+		VelocityWeight:         dbSettings.VelocityWeight,
+	}
+}
+
+// gathers the features for a chirp about to be written by userID and scores them against the current settings.
+// qtx has to be the transaction that inserts the chirp: the lock holds the user's other chirps back until
+// it commits, so a burst of parallel posts can't all be counted before any of them is written.
+// the same body from different users isn't serialized, those can still undercount the duplicates by one
+func scoreChirp(ctx context.Context, qtx *database.Queries, userID uuid.UUID, body string) (moderation.SpamDecision, moderation.SpamFeatures, error) {
+	features := moderation.SpamFeatures{}
+	if err := qtx.LockUsersChirps(ctx, userID); err != nil {
+		return "", features, err
+	}
+	dbSettings, err := qtx.GetSpamSettings(ctx)
+	if err != nil {
+		return "", features, err
+	}
+	//windows and account age are measured on the database clock, the one created_at was written with
+	accountAgeHours, err := qtx.GetUserAccountAgeHours(ctx, userID)
+	if err != nil {
+		return "", features, err
+	}
+	duplicates, err := qtx.CountRecentDuplicateChirps(ctx, database.CountRecentDuplicateChirpsParams{
+		Body:          body,
+		WindowMinutes: dbSettings.DuplicateWindowMinutes,
+	})
+	if err != nil {
+		return "", features, err
+	}
+	recentChirps, err := qtx.CountUsersRecentChirps(ctx, database.CountUsersRecentChirpsParams{
+		UserID:        userID,
+		WindowMinutes: dbSettings.VelocityWindowMinutes,
+	})
+	if err != nil {
+		return "", features, err
+	}
+
+	features.DuplicateCount = int(duplicates)
+	features.RecentChirps = int(recentChirps)
+	features.AccountAgeHours = accountAgeHours
+	features.CountLinks(body)
+	decision := moderation.ScoreSpam(&features, moderation.SpamSettings{
+		ShadowLimitScore: dbSettings.ShadowLimitScore,
+		RejectScore:      dbSettings.RejectScore,
+		DuplicateWeight:  dbSettings.DuplicateWeight,
+		LinkWeight:       dbSettings.LinkWeight,
+		NewAccountAge:    time.Duration(dbSettings.NewAccountHours) * time.Hour,
+		NewAccountWeight: dbSettings.NewAccountWeight,
+		VelocityLimit:    int(dbSettings.VelocityLimit),
+		VelocityWeight:   dbSettings.VelocityWeight,
+	})
+	return decision, features, nil
+}
+
+// writes a chirp through the spam pipeline, every path that creates chirps goes through here inside its
+// own transaction. a high score writes it shadow limited. a reject isn't written at all: the decision
+// is still recorded and rejected comes back true, the caller commits that and refuses the chirp
+func createScoredChirp(ctx context.Context, qtx *database.Queries, params database.CreateChirpParams) (database.Chirp, bool, error) {
+	spamDecision, spamFeatures, err := scoreChirp(ctx, qtx, params.UserID, params.Body)
+	if err != nil {
+		return database.Chirp{}, false, err
+	}
+	if spamDecision == moderation.SpamReject {
+		err := recordSpamDecision(ctx, qtx, params.UserID, uuid.NullUUID{}, params.Body, spamDecision, spamFeatures)
+		return database.Chirp{}, true, err
+	}
+	if spamDecision == moderation.SpamShadowLimit {
+		params.ShadowLimitedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+	dbChirp, err := qtx.CreateChirp(ctx, params)
+	if err != nil {
+		return database.Chirp{}, false, err
+	}
+	err = recordSpamDecision(ctx, qtx, params.UserID,
+		uuid.NullUUID{UUID: dbChirp.ID, Valid: true}, params.Body, spamDecision, spamFeatures)
+	return dbChirp, false, err
+}
+
+// chirpID is empty for rejected chirps, the body is kept so they can still be reviewed
+func recordSpamDecision(ctx context.Context, qtx *database.Queries, userID uuid.UUID, chirpID uuid.NullUUID, body string, decision moderation.SpamDecision, features moderation.SpamFeatures) error {
+	rawFeatures, err := json.Marshal(features)
+	if err != nil {
+		return err
+	}
+	return qtx.CreateSpamDecision(ctx, database.CreateSpamDecisionParams{
+		UserID:   userID,
+		ChirpID:  chirpID,
+		Body:     body,
+		Score:    features.Score,
+		Decision: string(decision),
+		Features: rawFeatures,
+	})
+}
+
+func (cfg *apiConfig) GetSpamDecisions(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	if _, ok := cfg.getModeratorID(res, req); !ok {
+		return
+	}
+	decision := req.URL.Query().Get("decision")
+	if decision == "" {
+		decision = string(moderation.SpamShadowLimit)
+	}
+	validDecisions := []string{string(moderation.SpamAccept), string(moderation.SpamShadowLimit), string(moderation.SpamReject)}
+	if !slices.Contains(validDecisions, decision) {
+		err := errors.New("unknown spam decision")
+		ErrorResponseWriter(res, "decision must be accept, shadow_limit or reject", err, 400)
+		return
+	}
+	limit, offset, err := getPaginationParams(req)
+	if err != nil {
+		ErrorResponseWriter(res, "invalid pagination params", err, 400)
+		return
+	}
+
+	dbDecisions, err := cfg.DB.GetSpamDecisions(req.Context(), database.GetSpamDecisionsParams{
+		Decision: decision,
+		Limit:    limit,
+		Offset:   offset,
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for spam decisions in DB", err, 500)
+		return
+	}
+
+	selectedDecisions := []SpamDecision{}
+	for _, row := range dbDecisions {
+		selectedDecisions = append(selectedDecisions, spamDecisionFromDB(row))
+	}
+
+	successRes, err := json.Marshal(selectedDecisions)
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+// marks a decision reviewed. a shadow limited chirp marked as a false positive is released to everyone
+func (cfg *apiConfig) ReviewSpamDecision(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	moderatorID, ok := cfg.getModeratorID(res, req)
+	if !ok {
+		return
+	}
+	decisionID, err := uuid.Parse(req.PathValue("decisionId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+
+	type ReviewRequest struct {
+		FalsePositive *bool  `json:"false_positive"`
+		Notes         string `json:"notes"`
+	}
+	reqData, err := io.ReadAll(req.Body)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to read request body", err, 500)
+		return
+	}
+	var reviewReq ReviewRequest
+	if err := json.Unmarshal(reqData, &reviewReq); err != nil {
+		ErrorResponseWriter(res, "Failed to decode request body", err, 400)
+		return
+	}
+	if reviewReq.FalsePositive == nil {
+		err := errors.New("missing false_positive field")
+		ErrorResponseWriter(res, "request body must set false_positive", err, 400)
+		return
+	}
+	if len(reviewReq.Notes) > maxModerationNotesLength {
+		err := errors.New("notes too long")
+		ErrorResponseWriter(res, "notes must be 1000 characters or fewer", err, 400)
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to start DB transaction", err, 500)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	dbDecision, err := qtx.GetSpamDecisionForUpdate(req.Context(), decisionID)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to find spam decision with provided id in DB", err, 404)
+		return
+	}
+	moderatorNullID := uuid.NullUUID{UUID: moderatorID, Valid: true}
+	dbDecision, err = qtx.ReviewSpamDecision(req.Context(), database.ReviewSpamDecisionParams{
+		ReviewedBy:    moderatorNullID,
+		FalsePositive: sql.NullBool{Bool: *reviewReq.FalsePositive, Valid: true},
+		ID:            decisionID,
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to review spam decision in DB", err, 500)
+		return
+	}
+
+	if *reviewReq.FalsePositive && dbDecision.ChirpID.Valid {
+		released, err := qtx.ReleaseShadowLimitedChirp(req.Context(), dbDecision.ChirpID.UUID)
+		if err != nil {
+			ErrorResponseWriter(res, "Failed to release chirp in DB", err, 500)
+			return
+		}
+		//it was never announced while limited, so webhook subscribers, the replied-to author and
+		//anyone mentioned hear about it now
+		if released > 0 {
+			dbChirp, err := qtx.GetOneChirp(req.Context(), dbDecision.ChirpID.UUID)
+			if err != nil {
+				ErrorResponseWriter(res, "failed to find chirp with provided id in DB", err, 404)
+				return
+			}
+			if err := enqueueWebhookEvent(req.Context(), qtx, webhookChirpCreated, chirpFromDB(dbChirp)); err != nil {
+				ErrorResponseWriter(res, "Failed to enqueue webhook event", err, 500)
+				return
+			}
+			replyToAuthorID, err := notifyReplyTarget(req.Context(), qtx, dbChirp)
+			if err != nil {
+				ErrorResponseWriter(res, "Failed to write notification to DB", err, 500)
+				return
+			}
+			if err := notifyMentions(req.Context(), qtx, dbChirp, replyToAuthorID); err != nil {
+				ErrorResponseWriter(res, "Failed to write notification to DB", err, 500)
+				return
+			}
+		}
+	}
+	if err := qtx.CreateModerationAction(req.Context(), database.CreateModerationActionParams{
+		ModeratorID:  moderatorNullID,
+		Action:       moderationReviewSpam,
+		ChirpID:      dbDecision.ChirpID,
+		TargetUserID: uuid.NullUUID{UUID: dbDecision.UserID, Valid: true},
+		Notes:        reviewReq.Notes,
+	}); err != nil {
+		ErrorResponseWriter(res, "Failed to write moderation action to DB", err, 500)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		ErrorResponseWriter(res, "Failed to commit DB transaction", err, 500)
+		return
+	}
+
+	successRes, err := json.Marshal(spamDecisionFromDB(dbDecision))
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) GetSpamSettings(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	if _, ok := cfg.getModeratorID(res, req); !ok {
+		return
+	}
+	dbSettings, err := cfg.DB.GetSpamSettings(req.Context())
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for spam settings in DB", err, 500)
+		return
+	}
+
+	successRes, err := json.Marshal(spamSettingsFromDB(dbSettings))
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+// replaces every setting at once, the next chirp is scored with the new values
+func (cfg *apiConfig) UpdateSpamSettings(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+
+	moderatorID, ok := cfg.getModeratorID(res, req)
+	if !ok {
+		return
+	}
+	reqData, err := io.ReadAll(req.Body)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to read request body", err, 500)
+		return
+	}
+	var settingsReq SpamSettings
+	if err := json.Unmarshal(reqData, &settingsReq); err != nil {
+		ErrorResponseWriter(res, "Failed to decode request body", err, 400)
+		return
+	}
+	if settingsReq.ShadowLimitScore <= 0 || settingsReq.RejectScore < settingsReq.ShadowLimitScore {
+		err := errors.New("invalid score thresholds")
+		ErrorResponseWriter(res, "shadow_limit_score must be positive and no higher than reject_score", err, 400)
+		return
+	}
+	if settingsReq.DuplicateWindowMinutes < 1 || settingsReq.VelocityWindowMinutes < 1 ||
+		settingsReq.NewAccountHours < 0 || settingsReq.VelocityLimit < 0 {
+		err := errors.New("invalid windows")
+		ErrorResponseWriter(res, "windows must be at least 1 minute and limits can't be negative", err, 400)
+		return
+	}
+	if settingsReq.DuplicateWeight < 0 || settingsReq.LinkWeight < 0 ||
+		settingsReq.NewAccountWeight < 0 || settingsReq.VelocityWeight < 0 {
+		err := errors.New("negative weight")
+		ErrorResponseWriter(res, "weights can't be negative", err, 400)
+		return
+	}
+
+	dbSettings, err := cfg.DB.UpdateSpamSettings(req.Context(), database.UpdateSpamSettingsParams{
+		UpdatedBy:              uuid.NullUUID{UUID: moderatorID, Valid: true},
+		ShadowLimitScore:       settingsReq.ShadowLimitScore,
+		RejectScore:            settingsReq.RejectScore,
+		DuplicateWindowMinutes: settingsReq.DuplicateWindowMinutes,
+		DuplicateWeight:        settingsReq.DuplicateWeight,
+		LinkWeight:             settingsReq.LinkWeight,
+		NewAccountHours:        settingsReq.NewAccountHours,
+		NewAccountWeight:       settingsReq.NewAccountWeight,
+		VelocityWindowMinutes:  settingsReq.VelocityWindowMinutes,
+		VelocityLimit:          settingsReq.VelocityLimit,
+		VelocityWeight:         settingsReq.VelocityWeight,
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to update spam settings in DB", err, 500)
+		return
+	}
+
+	successRes, err := json.Marshal(spamSettingsFromDB(dbSettings))
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
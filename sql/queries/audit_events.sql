@@ -0,0 +1,37 @@
+-- name: LockAuditChain :exec
+SELECT pg_advisory_xact_lock(hashtext('audit_events'));
+
+-- name: GetLatestAuditHash :one
+SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1;
+
+-- name: CreateAuditEvent :one
+INSERT INTO audit_events (created_at, action, actor_id, target_type, target_id, ip, user_agent, details, prev_hash, hash)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10
+)
+RETURNING *;
+
+-- name: GetAuditEvents :many
+SELECT * FROM audit_events
+WHERE (sqlc.narg(action)::text IS NULL OR action = sqlc.narg(action))
+AND (sqlc.narg(actor_id)::uuid IS NULL OR actor_id = sqlc.narg(actor_id))
+AND (sqlc.narg(target_type)::text IS NULL OR target_type = sqlc.narg(target_type))
+AND (sqlc.narg(target_id)::text IS NULL OR target_id = sqlc.narg(target_id))
+AND (sqlc.narg(since)::timestamp IS NULL OR created_at >= sqlc.narg(since))
+AND (sqlc.narg(until)::timestamp IS NULL OR created_at < sqlc.narg(until))
+ORDER BY id DESC
+LIMIT sqlc.arg(row_limit) OFFSET sqlc.arg(row_offset);
+
+-- name: GetAuditEventsAfter :many
+SELECT * FROM audit_events WHERE id > $1
+ORDER BY id
+LIMIT $2;
@@ -0,0 +1,33 @@
+-- name: CreateUserBlock :exec
+INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: DeleteUserBlock :execrows
+DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: CreateUserMute :exec
+INSERT INTO user_mutes (muter_id, muted_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (muter_id, muted_id) DO NOTHING;
+
+-- name: DeleteUserMute :execrows
+DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: IsBlockedByAny :one
+SELECT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE user_blocks.blocker_id = ANY(sqlc.arg(blocker_ids)::uuid[]) AND user_blocks.blocked_id = sqlc.arg(blocked_id)
+);
+
+-- name: IsBlockedInConversation :one
+SELECT EXISTS (
+    SELECT 1 FROM user_blocks
+    JOIN conversation_participants ON conversation_participants.user_id = user_blocks.blocker_id
+    WHERE conversation_participants.conversation_id = $1 AND user_blocks.blocked_id = $2
+);
+
+-- name: GetHiddenAuthorIDs :many
+SELECT blocked_id AS author_id FROM user_blocks WHERE blocker_id = $1
+UNION
+SELECT muted_id AS author_id FROM user_mutes WHERE muter_id = $1;
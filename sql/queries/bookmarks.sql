@@ -0,0 +1,51 @@
+-- name: CreateBookmark :one
+INSERT INTO bookmarks (user_id, chirp_id, folder_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW()
+)
+ON CONFLICT (user_id, chirp_id) DO UPDATE SET folder_id = COALESCE(EXCLUDED.folder_id, bookmarks.folder_id)
+RETURNING *;
+
+-- name: MoveBookmark :one
+UPDATE bookmarks SET folder_id = $1 WHERE user_id = $2 AND chirp_id = $3
+RETURNING *;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetUserBookmarks :many
+SELECT chirps.*, bookmarks.folder_id, bookmarks.created_at AS bookmarked_at
+FROM bookmarks JOIN chirps ON chirps.id = bookmarks.chirp_id
+WHERE bookmarks.user_id = $1 AND chirps.hidden_at IS NULL
+ORDER BY bookmarks.created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: GetFolderBookmarks :many
+SELECT chirps.*, bookmarks.folder_id, bookmarks.created_at AS bookmarked_at
+FROM bookmarks JOIN chirps ON chirps.id = bookmarks.chirp_id
+WHERE bookmarks.user_id = $1 AND bookmarks.folder_id = $2 AND chirps.hidden_at IS NULL
+ORDER BY bookmarks.created_at DESC
+LIMIT $3 OFFSET $4;
+
+-- name: CreateBookmarkFolder :one
+INSERT INTO bookmark_folders (id, created_at, updated_at, user_id, name)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2
+)
+RETURNING *;
+
+-- name: GetBookmarkFolders :many
+SELECT * FROM bookmark_folders WHERE user_id = $1 ORDER BY name;
+
+-- name: GetBookmarkFolder :one
+SELECT * FROM bookmark_folders WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteBookmarkFolder :execrows
+DELETE FROM bookmark_folders WHERE id = $1 AND user_id = $2;
@@ -0,0 +1,18 @@
+-- name: SequenceChirpEvents :one
+SELECT sequence_chirp_events()::bigint AS sequenced;
+
+-- name: GetChirpEventsAfter :many
+SELECT * FROM chirp_events WHERE seq > $1
+ORDER BY seq
+LIMIT $2;
+
+-- name: GetLatestChirpEventSeq :one
+SELECT COALESCE(MAX(seq), 0)::bigint AS latest FROM chirp_events;
+
+-- name: GetLatestChirpEventTime :one
+SELECT COALESCE(MAX(created_at), 'epoch')::timestamp AS latest FROM chirp_events;
+
+-- name: DeleteOldChirpEvents :exec
+DELETE FROM chirp_events
+WHERE created_at < NOW() - sqlc.arg(retention_seconds)::float8 * INTERVAL '1 second'
+    AND seq < (SELECT MAX(seq) FROM chirp_events);
@@ -1,22 +1,63 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id) 
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, shadow_limited_at, reply_to_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4
 )
 RETURNING *;
 
 -- name: GetAllChirps :many
-SELECT * FROM chirps ORDER BY created_at;
+SELECT * FROM chirps
+WHERE hidden_at IS NULL AND (shadow_limited_at IS NULL OR user_id = sqlc.narg(viewer_id)) AND NOT EXISTS (
+    SELECT 1 FROM user_blocks WHERE user_blocks.blocker_id = sqlc.narg(viewer_id) AND user_blocks.blocked_id = chirps.user_id
+) AND NOT EXISTS (
+    SELECT 1 FROM user_mutes WHERE user_mutes.muter_id = sqlc.narg(viewer_id) AND user_mutes.muted_id = chirps.user_id
+)
+ORDER BY created_at;
 
 -- name: GetAuthorsChirps :many
-SELECT * FROM chirps WHERE user_id = $1 ORDER BY created_at;
+SELECT * FROM chirps
+WHERE user_id = sqlc.arg(user_id) AND hidden_at IS NULL AND (shadow_limited_at IS NULL OR user_id = sqlc.narg(viewer_id)) AND NOT EXISTS (
+    SELECT 1 FROM user_blocks WHERE user_blocks.blocker_id = sqlc.narg(viewer_id) AND user_blocks.blocked_id = chirps.user_id
+) AND NOT EXISTS (
+    SELECT 1 FROM user_mutes WHERE user_mutes.muter_id = sqlc.narg(viewer_id) AND user_mutes.muted_id = chirps.user_id
+)
+ORDER BY created_at;
 
 -- name: GetOneChirp :one
 SELECT * FROM chirps WHERE id = $1;
 
 -- name: DeleteOneChirp :exec
-DELETE FROM chirps WHERE id = $1;
\ No newline at end of file
+DELETE FROM chirps WHERE id = $1;
+
+-- name: DeleteChirpIfUnchanged :execrows
+DELETE FROM chirps
+WHERE id = sqlc.arg(id) AND (sqlc.narg(if_updated_at)::timestamp IS NULL OR updated_at = sqlc.narg(if_updated_at));
+
+-- name: UpdateChirpBody :one
+UPDATE chirps SET body = sqlc.arg(body), updated_at = NOW()
+WHERE id = sqlc.arg(id) AND (sqlc.narg(if_updated_at)::timestamp IS NULL OR updated_at = sqlc.narg(if_updated_at))
+RETURNING *;
+
+-- name: HideChirp :execrows
+UPDATE chirps SET hidden_at = NOW(), updated_at = NOW() WHERE id = $1 AND hidden_at IS NULL;
+
+-- name: ReleaseShadowLimitedChirp :execrows
+UPDATE chirps SET shadow_limited_at = NULL WHERE id = $1 AND shadow_limited_at IS NOT NULL;
+
+-- name: CountRecentDuplicateChirps :one
+SELECT COUNT(*) FROM chirps
+WHERE md5(lower(body)) = md5(lower(sqlc.arg(body)::text))
+AND created_at > NOW() - make_interval(mins => sqlc.arg(window_minutes)::int);
+
+-- name: CountUsersRecentChirps :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = sqlc.arg(user_id) AND created_at > NOW() - make_interval(mins => sqlc.arg(window_minutes)::int);
+
+-- name: LockUsersChirps :exec
+SELECT pg_advisory_xact_lock(hashtext('chirps:' || sqlc.arg(user_id)::uuid::text));
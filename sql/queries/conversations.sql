@@ -0,0 +1,83 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, created_by)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1
+)
+RETURNING *;
+
+-- name: AddConversationParticipant :exec
+INSERT INTO conversation_participants (conversation_id, user_id, joined_at)
+VALUES ($1, $2, NOW());
+
+-- name: UpsertDirectConversation :one
+INSERT INTO conversations (id, created_at, updated_at, created_by, direct_key)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2
+)
+ON CONFLICT (direct_key) DO UPDATE SET direct_key = EXCLUDED.direct_key
+RETURNING *, (xmax = 0)::bool AS created;
+
+-- name: GetConversationParticipant :one
+SELECT * FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: GetConversationsParticipants :many
+SELECT * FROM conversation_participants
+WHERE conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[])
+ORDER BY conversation_id, joined_at;
+
+-- name: GetUsersConversations :many
+SELECT
+    conversations.id,
+    conversations.created_at,
+    conversations.updated_at,
+    conversations.created_by,
+    conversations.last_message_at,
+    last_message.id AS last_message_id,
+    last_message.sender_id AS last_message_sender_id,
+    last_message.body AS last_message_body,
+    last_message.created_at AS last_message_created_at,
+    (
+        SELECT COUNT(*) FROM messages AS unread
+        WHERE unread.conversation_id = conversations.id
+        AND unread.sender_id <> conversation_participants.user_id
+        AND (conversation_participants.last_read_at IS NULL OR unread.created_at > conversation_participants.last_read_at)
+    ) AS unread_count
+FROM conversation_participants
+JOIN conversations ON conversations.id = conversation_participants.conversation_id
+LEFT JOIN LATERAL (
+    SELECT * FROM messages WHERE messages.conversation_id = conversations.id
+    ORDER BY messages.created_at DESC
+    LIMIT 1
+) AS last_message ON true
+WHERE conversation_participants.user_id = $1
+ORDER BY COALESCE(conversations.last_message_at, conversations.created_at) DESC
+LIMIT $2 OFFSET $3;
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, created_at, conversation_id, sender_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations SET last_message_at = $1, updated_at = NOW() WHERE id = $2;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_participants SET last_read_at = NOW() WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: GetConversationMessages :many
+SELECT * FROM messages WHERE conversation_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
@@ -0,0 +1,26 @@
+-- name: CreateDraft :one
+INSERT INTO drafts (id, created_at, updated_at, body, user_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2
+)
+RETURNING *;
+
+-- name: GetUsersDrafts :many
+SELECT * FROM drafts WHERE user_id = $1 ORDER BY updated_at DESC;
+
+-- name: GetDraft :one
+SELECT * FROM drafts WHERE id = $1 AND user_id = $2;
+
+-- name: GetDraftForUpdate :one
+SELECT * FROM drafts WHERE id = $1 AND user_id = $2 FOR UPDATE;
+
+-- name: UpdateDraft :one
+UPDATE drafts SET body = $1, updated_at = NOW() WHERE id = $2 AND user_id = $3
+RETURNING *;
+
+-- name: DeleteDraft :execrows
+DELETE FROM drafts WHERE id = $1 AND user_id = $2;
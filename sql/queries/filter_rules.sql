@@ -0,0 +1,23 @@
+-- name: GetFilterRules :many
+SELECT * FROM filter_rules ORDER BY locale, pattern;
+
+-- name: CreateFilterRule :one
+INSERT INTO filter_rules (id, created_at, updated_at, pattern, locale, actions, created_by)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: UpdateFilterRule :one
+UPDATE filter_rules SET pattern = $1, locale = $2, actions = $3, updated_at = NOW()
+WHERE id = $4
+RETURNING *;
+
+-- name: DeleteFilterRule :execrows
+DELETE FROM filter_rules WHERE id = $1;
@@ -0,0 +1,10 @@
+-- name: CreateUserFollow :execrows
+INSERT INTO user_follows (follower_id, followed_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (follower_id, followed_id) DO NOTHING;
+
+-- name: DeleteUserFollow :execrows
+DELETE FROM user_follows WHERE follower_id = $1 AND followed_id = $2;
+
+-- name: GetFollowedIDs :many
+SELECT followed_id FROM user_follows WHERE follower_id = $1;
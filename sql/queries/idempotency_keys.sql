@@ -0,0 +1,28 @@
+-- name: ClaimIdempotencyKey :one
+INSERT INTO idempotency_keys (scope, key, created_at, expires_at, request_hash)
+VALUES (
+    sqlc.arg(scope),
+    sqlc.arg(key),
+    NOW(),
+    NOW() + sqlc.arg(ttl_seconds)::float8 * INTERVAL '1 second',
+    sqlc.arg(request_hash)
+)
+ON CONFLICT (scope, key) DO UPDATE SET
+    created_at = EXCLUDED.created_at,
+    expires_at = EXCLUDED.expires_at,
+    request_hash = EXCLUDED.request_hash,
+    status_code = NULL,
+    content_type = '',
+    response_body = NULL
+WHERE idempotency_keys.expires_at < NOW()
+RETURNING *;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys WHERE scope = $1 AND key = $2;
+
+-- name: SaveIdempotentResponse :exec
+UPDATE idempotency_keys SET status_code = $3, content_type = $4, response_body = $5
+WHERE scope = $1 AND key = $2;
+
+-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys WHERE expires_at < NOW();
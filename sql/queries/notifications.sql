@@ -0,0 +1,54 @@
+-- name: UpsertNotification :exec
+INSERT INTO notifications (id, created_at, updated_at, user_id, type, group_key, chirp_id, actor_ids, event_count)
+SELECT
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    sqlc.arg(user_id)::uuid,
+    sqlc.arg(type)::text,
+    sqlc.arg(group_key)::text,
+    sqlc.narg(chirp_id)::uuid,
+    sqlc.arg(actor_ids)::uuid[],
+    1
+WHERE NOT EXISTS (
+    SELECT 1 FROM notification_mutes
+    WHERE notification_mutes.user_id = sqlc.arg(user_id)::uuid AND notification_mutes.type = sqlc.arg(type)::text
+) AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE user_blocks.blocker_id = sqlc.arg(user_id)::uuid AND user_blocks.blocked_id = ANY(sqlc.arg(actor_ids)::uuid[])
+)
+ON CONFLICT (user_id, group_key) WHERE read_at IS NULL DO UPDATE SET
+    actor_ids = CASE WHEN EXCLUDED.actor_ids <@ notifications.actor_ids THEN notifications.actor_ids
+        ELSE notifications.actor_ids || EXCLUDED.actor_ids END,
+    event_count = notifications.event_count + 1,
+    updated_at = NOW();
+
+-- name: GetNotification :one
+SELECT * FROM notifications WHERE id = $1;
+
+-- name: GetUsersNotifications :many
+SELECT * FROM notifications
+WHERE user_id = sqlc.arg(user_id) AND (NOT sqlc.arg(unread_only)::bool OR read_at IS NULL)
+ORDER BY updated_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications SET read_at = NOW()
+WHERE user_id = sqlc.arg(user_id) AND id = ANY(sqlc.arg(ids)::uuid[]) AND read_at IS NULL;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: GetNotificationMutes :many
+SELECT * FROM notification_mutes WHERE user_id = $1 ORDER BY type;
+
+-- name: CreateNotificationMute :exec
+INSERT INTO notification_mutes (user_id, type, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, type) DO NOTHING;
+
+-- name: DeleteNotificationMute :execrows
+DELETE FROM notification_mutes WHERE user_id = $1 AND type = $2;
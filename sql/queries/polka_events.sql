@@ -0,0 +1,16 @@
+-- name: CreatePolkaEvent :one
+INSERT INTO polka_events (id, created_at, event, user_id, result, payload, event_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    'pending',
+    $3,
+    $4
+)
+ON CONFLICT (event_id) DO NOTHING
+RETURNING *;
+
+-- name: SetPolkaEventResult :exec
+UPDATE polka_events SET result = $1 WHERE id = $2;
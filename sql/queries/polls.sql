@@ -0,0 +1,51 @@
+-- name: CreatePoll :one
+INSERT INTO polls (id, created_at, updated_at, chirp_id, allows_multiple, expires_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: CreatePollOption :one
+INSERT INTO poll_options (id, poll_id, position, text)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: GetPollsForChirps :many
+SELECT * FROM polls WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: GetPollForUpdate :one
+SELECT * FROM polls WHERE chirp_id = $1 FOR UPDATE;
+
+-- name: GetPollTallies :many
+SELECT poll_options.id, poll_options.poll_id, poll_options.position, poll_options.text, COUNT(poll_votes.user_id) AS votes
+FROM poll_options LEFT JOIN poll_votes ON poll_votes.option_id = poll_options.id
+WHERE poll_options.poll_id = ANY(sqlc.arg(poll_ids)::uuid[])
+GROUP BY poll_options.id
+ORDER BY poll_options.poll_id, poll_options.position;
+
+-- name: GetUsersPollVotes :many
+SELECT poll_id, option_id FROM poll_votes
+WHERE user_id = sqlc.arg(user_id) AND poll_id = ANY(sqlc.arg(poll_ids)::uuid[]);
+
+-- name: CreatePollVote :exec
+INSERT INTO poll_votes (poll_id, option_id, user_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW()
+)
+ON CONFLICT (option_id, user_id) DO NOTHING;
+
+-- name: TouchPoll :exec
+UPDATE polls SET updated_at = NOW() WHERE id = $1;
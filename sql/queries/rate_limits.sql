@@ -0,0 +1,5 @@
+-- name: TakeRateLimitToken :one
+SELECT allowed, tokens FROM take_rate_limit_token(sqlc.arg(key), sqlc.arg(capacity)::float8, sqlc.arg(refill_per_second)::float8);
+
+-- name: DeleteIdleRateLimitBuckets :execrows
+DELETE FROM rate_limit_buckets WHERE updated_at < NOW() - sqlc.arg(idle_seconds)::float8 * INTERVAL '1 second';
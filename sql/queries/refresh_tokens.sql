@@ -13,5 +13,9 @@ RETURNING *;
 -- name: GetUserFromRefreshToken :one
 SELECT user_id FROM refresh_tokens WHERE token = $1 AND expires_at > NOW() AND revoked_at IS NULL;
 
--- name: RevokeRefreshTokenFromDB :exec
-UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW() WHERE token = $1;
\ No newline at end of file
+-- name: RevokeRefreshTokenFromDB :one
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW() WHERE token = $1
+RETURNING user_id;
+
+-- name: RevokeUsersRefreshTokens :exec
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL;
@@ -0,0 +1,56 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, reporter_id, chirp_id, chirp_body, target_user_id, reason, details, status)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    'open'
+)
+ON CONFLICT DO NOTHING
+RETURNING *;
+
+-- name: GetReports :many
+SELECT * FROM reports WHERE status = $1
+ORDER BY created_at
+LIMIT $2 OFFSET $3;
+
+-- name: GetReportForUpdate :one
+SELECT * FROM reports WHERE id = $1 FOR UPDATE;
+
+-- name: ClaimReport :one
+UPDATE reports SET status = 'claimed', claimed_by = $1, claimed_at = NOW(), updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: ResolveReport :one
+UPDATE reports SET status = 'resolved', resolved_by = $1, resolved_at = NOW(), resolution = $2, updated_at = NOW()
+WHERE id = $3
+RETURNING *;
+
+-- name: ResolveOpenChirpReports :exec
+UPDATE reports SET status = 'resolved', resolved_by = $1, resolved_at = NOW(), resolution = $2, updated_at = NOW()
+WHERE chirp_id = $3 AND status <> 'resolved';
+
+-- name: CreateModerationAction :exec
+INSERT INTO moderation_actions (id, created_at, moderator_id, report_id, action, chirp_id, target_user_id, notes)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+);
+
+-- name: GetModerationActions :many
+SELECT * FROM moderation_actions
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2;
@@ -0,0 +1,39 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, created_at, updated_at, body, user_id, publish_at, locale)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetUsersScheduledChirps :many
+SELECT * FROM scheduled_chirps WHERE user_id = $1 ORDER BY publish_at;
+
+-- name: RescheduleChirp :one
+UPDATE scheduled_chirps SET publish_at = $1, updated_at = NOW() WHERE id = $2 AND user_id = $3
+RETURNING *;
+
+-- name: CancelScheduledChirp :execrows
+DELETE FROM scheduled_chirps WHERE id = $1 AND user_id = $2;
+
+-- name: ClaimDueScheduledChirps :many
+SELECT * FROM scheduled_chirps WHERE publish_at <= NOW()
+AND NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = scheduled_chirps.user_id AND users.suspended_at IS NOT NULL
+)
+ORDER BY publish_at
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: DeleteScheduledChirp :exec
+DELETE FROM scheduled_chirps WHERE id = $1;
+
+-- name: DeleteSuspendedDueScheduledChirps :execrows
+DELETE FROM scheduled_chirps USING users
+WHERE users.id = scheduled_chirps.user_id AND users.suspended_at IS NOT NULL
+AND scheduled_chirps.publish_at <= NOW();
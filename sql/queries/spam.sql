@@ -0,0 +1,45 @@
+-- name: GetSpamSettings :one
+SELECT * FROM spam_settings WHERE id = TRUE;
+
+-- name: UpdateSpamSettings :one
+UPDATE spam_settings SET
+    updated_at = NOW(),
+    updated_by = $1,
+    shadow_limit_score = $2,
+    reject_score = $3,
+    duplicate_window_minutes = $4,
+    duplicate_weight = $5,
+    link_weight = $6,
+    new_account_hours = $7,
+    new_account_weight = $8,
+    velocity_window_minutes = $9,
+    velocity_limit = $10,
+    velocity_weight = $11
+WHERE id = TRUE
+RETURNING *;
+
+-- name: CreateSpamDecision :exec
+INSERT INTO spam_decisions (id, created_at, user_id, chirp_id, body, score, decision, features)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+);
+
+-- name: GetSpamDecisions :many
+SELECT * FROM spam_decisions WHERE decision = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: GetSpamDecisionForUpdate :one
+SELECT * FROM spam_decisions WHERE id = $1 FOR UPDATE;
+
+-- name: ReviewSpamDecision :one
+UPDATE spam_decisions SET reviewed_by = $1, reviewed_at = NOW(), false_positive = $2
+WHERE id = $3
+RETURNING *;
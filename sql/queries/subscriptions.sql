@@ -0,0 +1,35 @@
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_end, source)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (user_id, plan) DO UPDATE SET
+    status = EXCLUDED.status,
+    current_period_end = EXCLUDED.current_period_end,
+    source = EXCLUDED.source,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: GetActiveSubscription :one
+SELECT * FROM subscriptions
+WHERE user_id = $1 AND status IN ('active', 'past_due') AND current_period_end > NOW()
+ORDER BY current_period_end DESC
+LIMIT 1;
+
+-- name: ExpireSubscriptions :many
+UPDATE subscriptions SET status = 'expired', updated_at = NOW()
+WHERE status IN ('active', 'past_due') AND current_period_end <= NOW()
+RETURNING user_id;
+
+-- name: GetSubscription :one
+SELECT * FROM subscriptions WHERE user_id = $1 AND plan = $2;
+
+-- name: SetSubscriptionStatus :execrows
+UPDATE subscriptions SET status = $1, updated_at = NOW() WHERE user_id = $2 AND plan = $3;
@@ -19,5 +19,34 @@ SELECT * FROM users WHERE email = $1;
 UPDATE users SET email = $1, pw_hash = $2, updated_at = NOW() WHERE id = $3
 RETURNING  *;
 
--- name: UpgradeUserToRed :exec
-UPDATE users SET is_chirpy_red = true, updated_at = NOW() WHERE id = $1;
\ No newline at end of file
+-- name: GetUser :one
+SELECT * FROM users WHERE id = $1;
+
+-- name: GetUserAccountAgeHours :one
+SELECT (EXTRACT(EPOCH FROM NOW() - created_at) / 3600)::float8 FROM users WHERE id = $1;
+
+-- name: SyncUserChirpyRed :exec
+UPDATE users SET is_chirpy_red = EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE subscriptions.user_id = users.id
+    AND subscriptions.status IN ('active', 'past_due')
+    AND subscriptions.current_period_end > NOW()
+), updated_at = NOW()
+WHERE id = $1;
+
+-- name: SuspendUser :execrows
+UPDATE users SET suspended_at = NOW(), updated_at = NOW() WHERE id = $1 AND suspended_at IS NULL;
+
+-- name: UnsuspendUser :execrows
+UPDATE users SET suspended_at = NULL, updated_at = NOW() WHERE id = $1 AND suspended_at IS NOT NULL;
+
+-- name: SetUserRole :one
+UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2
+RETURNING *;
+
+-- name: SetUserHandle :one
+UPDATE users SET handle = $1, updated_at = NOW() WHERE id = $2
+RETURNING *;
+
+-- name: GetUserIDsByHandles :many
+SELECT id FROM users WHERE handle = ANY(sqlc.arg(handles)::text[]);
@@ -0,0 +1,73 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (id, created_at, updated_at, user_id, url, secret, events, active)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    true
+)
+RETURNING *;
+
+-- name: GetUsersWebhookSubscriptions :many
+SELECT * FROM webhook_subscriptions WHERE user_id = $1 ORDER BY created_at;
+
+-- name: GetAdminWebhookSubscriptions :many
+SELECT * FROM webhook_subscriptions WHERE user_id IS NULL ORDER BY created_at;
+
+-- name: GetWebhookSubscription :one
+SELECT * FROM webhook_subscriptions WHERE id = $1;
+
+-- name: DeleteWebhookSubscription :exec
+DELETE FROM webhook_subscriptions WHERE id = $1;
+
+-- name: EnqueueWebhookEvent :execrows
+INSERT INTO webhook_deliveries (id, created_at, updated_at, subscription_id, event_type, payload, status, attempts, next_attempt_at)
+SELECT gen_random_uuid(), NOW(), NOW(), webhook_subscriptions.id, sqlc.arg(event_type)::text, sqlc.arg(payload)::jsonb, 'pending', 0, NOW()
+FROM webhook_subscriptions
+WHERE webhook_subscriptions.active AND sqlc.arg(event_type)::text = ANY(webhook_subscriptions.events)
+    AND (webhook_subscriptions.user_id IS NULL OR NOT sqlc.arg(admin_only)::bool
+        OR webhook_subscriptions.user_id = sqlc.narg(audience_id)::uuid);
+
+-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries SET next_attempt_at = NOW() + INTERVAL '2 minutes', updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkWebhookDelivered :exec
+UPDATE webhook_deliveries SET
+    status = 'delivered',
+    attempts = attempts + 1,
+    last_status_code = $1,
+    last_error = NULL,
+    delivered_at = NOW(),
+    updated_at = NOW()
+WHERE id = $2;
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries SET
+    status = $1,
+    attempts = attempts + 1,
+    next_attempt_at = $2,
+    last_status_code = $3,
+    last_error = $4,
+    updated_at = NOW()
+WHERE id = $5;
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries WHERE subscription_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: RedriveWebhookDelivery :execrows
+UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND subscription_id = $2 AND status = 'dead';
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL,
+    current_period_end TIMESTAMP NOT NULL,
+    source TEXT NOT NULL,
+    UNIQUE (user_id, plan)
+);
+
+-- users that were already upgraded get a normal billing period, polka renewals take it from there
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_end, source)
+SELECT gen_random_uuid(), NOW(), NOW(), id, 'chirpy_red', 'active', NOW() + INTERVAL '30 days', 'legacy'
+FROM users WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscriptions;
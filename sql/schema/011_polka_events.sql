@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE polka_events (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    event TEXT NOT NULL,
+    user_id UUID,
+    result TEXT NOT NULL,
+    payload JSONB NOT NULL
+);
+
+CREATE INDEX polka_events_user_id_idx ON polka_events (user_id);
+
+-- +goose Down
+DROP TABLE polka_events;
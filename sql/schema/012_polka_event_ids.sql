@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE polka_events ADD COLUMN IF NOT EXISTS event_id TEXT UNIQUE;
+
+-- +goose Down
+ALTER TABLE polka_events DROP COLUMN IF EXISTS event_id;
@@ -0,0 +1,44 @@
+-- +goose Up
+CREATE TABLE chirp_events (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    event_type TEXT NOT NULL,
+    chirp_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    body TEXT NOT NULL,
+    chirp_created_at TIMESTAMP NOT NULL,
+    chirp_updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX chirp_events_created_at_idx ON chirp_events (created_at);
+
+-- every insert/delete on chirps lands in chirp_events and wakes up the stream listeners.
+-- NOTIFY is only delivered once the writing transaction commits
+-- +goose StatementBegin
+CREATE FUNCTION record_chirp_event() RETURNS trigger AS $$
+DECLARE
+    event_id BIGINT;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO chirp_events (event_type, chirp_id, user_id, body, chirp_created_at, chirp_updated_at)
+        VALUES ('chirp.created', NEW.id, NEW.user_id, NEW.body, NEW.created_at, NEW.updated_at)
+        RETURNING id INTO event_id;
+    ELSE
+        INSERT INTO chirp_events (event_type, chirp_id, user_id, body, chirp_created_at, chirp_updated_at)
+        VALUES ('chirp.deleted', OLD.id, OLD.user_id, OLD.body, OLD.created_at, OLD.updated_at)
+        RETURNING id INTO event_id;
+    END IF;
+    PERFORM pg_notify('chirp_events', event_id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_record_event
+AFTER INSERT OR DELETE ON chirps
+FOR EACH ROW EXECUTE FUNCTION record_chirp_event();
+
+-- +goose Down
+DROP TRIGGER chirps_record_event ON chirps;
+DROP FUNCTION record_chirp_event();
+DROP TABLE chirp_events;
@@ -0,0 +1,44 @@
+-- +goose Up
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    group_key TEXT NOT NULL,
+    chirp_id UUID REFERENCES chirps (id) ON DELETE CASCADE,
+    actor_ids UUID[] NOT NULL DEFAULT '{}',
+    event_count INT NOT NULL DEFAULT 1,
+    read_at TIMESTAMP
+);
+
+-- repeated events fold into the one unread notification of their group, once it's read a new one starts
+CREATE UNIQUE INDEX notifications_unread_group_idx ON notifications (user_id, group_key) WHERE read_at IS NULL;
+CREATE INDEX notifications_user_id_idx ON notifications (user_id, updated_at);
+
+CREATE TABLE notification_mutes (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, type)
+);
+
+-- wakes up the same listeners as chirp_events so open websockets get new notifications
+-- +goose StatementBegin
+CREATE FUNCTION notify_notification() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('notifications', NEW.id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER notifications_notify
+AFTER INSERT OR UPDATE ON notifications
+FOR EACH ROW WHEN (NEW.read_at IS NULL) EXECUTE FUNCTION notify_notification();
+
+-- +goose Down
+DROP TRIGGER notifications_notify ON notifications;
+DROP FUNCTION notify_notification();
+DROP TABLE notification_mutes;
+DROP TABLE notifications;
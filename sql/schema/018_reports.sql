@@ -0,0 +1,47 @@
+-- +goose Up
+ALTER TABLE chirps ADD COLUMN IF NOT EXISTS hidden_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMP;
+
+-- chirp reports keep a copy of the body so the report still makes sense after the chirp is deleted
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    reporter_id UUID REFERENCES users (id) ON DELETE SET NULL,
+    chirp_id UUID REFERENCES chirps (id) ON DELETE SET NULL,
+    chirp_body TEXT,
+    target_user_id UUID REFERENCES users (id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    claimed_by UUID REFERENCES users (id) ON DELETE SET NULL,
+    claimed_at TIMESTAMP,
+    resolved_by UUID REFERENCES users (id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    resolution TEXT
+);
+
+CREATE INDEX reports_status_idx ON reports (status, created_at);
+-- one open report per reporter and target
+CREATE UNIQUE INDEX reports_open_chirp_idx ON reports (reporter_id, chirp_id) WHERE chirp_id IS NOT NULL AND status <> 'resolved';
+CREATE UNIQUE INDEX reports_open_user_idx ON reports (reporter_id, target_user_id) WHERE chirp_id IS NULL AND status <> 'resolved';
+
+-- audit trail of everything moderators do, never updated or deleted
+CREATE TABLE moderation_actions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    moderator_id UUID REFERENCES users (id) ON DELETE SET NULL,
+    report_id UUID REFERENCES reports (id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    chirp_id UUID,
+    target_user_id UUID,
+    notes TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX moderation_actions_created_at_idx ON moderation_actions (created_at);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+ALTER TABLE users DROP COLUMN IF EXISTS suspended_at;
+ALTER TABLE chirps DROP COLUMN IF EXISTS hidden_at;
@@ -0,0 +1,39 @@
+-- +goose Up
+-- word list for the chirp content filter. patterns are matched after unicode/leetspeak folding,
+-- locale '' applies to every chirp
+CREATE TABLE filter_rules (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    pattern TEXT NOT NULL,
+    locale TEXT NOT NULL DEFAULT '',
+    actions TEXT[] NOT NULL,
+    created_by UUID REFERENCES users (id) ON DELETE SET NULL,
+    UNIQUE (pattern, locale)
+);
+
+-- the three words validateChirpHelper used to hardcode
+INSERT INTO filter_rules (id, created_at, updated_at, pattern, locale, actions)
+VALUES
+    (gen_random_uuid(), NOW(), NOW(), 'kerfuffle', '', '{mask}'),
+    (gen_random_uuid(), NOW(), NOW(), 'sharbert', '', '{mask}'),
+    (gen_random_uuid(), NOW(), NOW(), 'fornax', '', '{mask}');
+
+-- every server rebuilds its matcher when the list changes
+-- +goose StatementBegin
+CREATE FUNCTION notify_filter_rules() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('filter_rules', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER filter_rules_notify
+AFTER INSERT OR UPDATE OR DELETE ON filter_rules
+FOR EACH STATEMENT EXECUTE FUNCTION notify_filter_rules();
+
+-- +goose Down
+DROP TRIGGER filter_rules_notify ON filter_rules;
+DROP FUNCTION notify_filter_rules();
+DROP TABLE filter_rules;
@@ -0,0 +1,103 @@
+-- +goose Up
+-- shadow limited chirps are only shown to their author
+ALTER TABLE chirps ADD COLUMN IF NOT EXISTS shadow_limited_at TIMESTAMP;
+CREATE INDEX chirps_body_hash_idx ON chirps (md5(lower(body)), created_at);
+CREATE INDEX chirps_user_created_at_idx ON chirps (user_id, created_at);
+
+-- single row of scoring thresholds, edited through /admin/moderation/spam/settings
+CREATE TABLE spam_settings (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    updated_at TIMESTAMP NOT NULL,
+    updated_by UUID REFERENCES users (id) ON DELETE SET NULL,
+    shadow_limit_score DOUBLE PRECISION NOT NULL,
+    reject_score DOUBLE PRECISION NOT NULL,
+    duplicate_window_minutes INTEGER NOT NULL,
+    duplicate_weight DOUBLE PRECISION NOT NULL,
+    link_weight DOUBLE PRECISION NOT NULL,
+    new_account_hours INTEGER NOT NULL,
+    new_account_weight DOUBLE PRECISION NOT NULL,
+    velocity_window_minutes INTEGER NOT NULL,
+    velocity_limit INTEGER NOT NULL,
+    velocity_weight DOUBLE PRECISION NOT NULL
+);
+
+INSERT INTO spam_settings (updated_at, shadow_limit_score, reject_score, duplicate_window_minutes, duplicate_weight,
+    link_weight, new_account_hours, new_account_weight, velocity_window_minutes, velocity_limit, velocity_weight)
+VALUES (NOW(), 5, 10, 60, 3, 4, 24, 2, 10, 10, 1);
+
+-- every scored chirp, so moderators can go back over false positives.
+-- rejected chirps never exist, the body is kept here instead
+CREATE TABLE spam_decisions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    chirp_id UUID REFERENCES chirps (id) ON DELETE SET NULL,
+    body TEXT NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    decision TEXT NOT NULL,
+    features JSONB NOT NULL,
+    reviewed_by UUID REFERENCES users (id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP,
+    false_positive BOOLEAN
+);
+
+CREATE INDEX spam_decisions_decision_idx ON spam_decisions (decision, created_at);
+
+-- shadow limited chirps stay off the live streams until a moderator releases them
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_chirp_event() RETURNS trigger AS $$
+DECLARE
+    event_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF OLD.shadow_limited_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO chirp_events (event_type, chirp_id, user_id, body, chirp_created_at, chirp_updated_at)
+        VALUES ('chirp.deleted', OLD.id, OLD.user_id, OLD.body, OLD.created_at, OLD.updated_at)
+        RETURNING id INTO event_id;
+    ELSE
+        IF NEW.shadow_limited_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO chirp_events (event_type, chirp_id, user_id, body, chirp_created_at, chirp_updated_at)
+        VALUES ('chirp.created', NEW.id, NEW.user_id, NEW.body, NEW.created_at, NEW.updated_at)
+        RETURNING id INTO event_id;
+    END IF;
+    PERFORM pg_notify('chirp_events', event_id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_record_release
+AFTER UPDATE OF shadow_limited_at ON chirps
+FOR EACH ROW WHEN (OLD.shadow_limited_at IS NOT NULL AND NEW.shadow_limited_at IS NULL)
+EXECUTE FUNCTION record_chirp_event();
+
+-- +goose Down
+DROP TRIGGER chirps_record_release ON chirps;
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_chirp_event() RETURNS trigger AS $$
+DECLARE
+    event_id BIGINT;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO chirp_events (event_type, chirp_id, user_id, body, chirp_created_at, chirp_updated_at)
+        VALUES ('chirp.created', NEW.id, NEW.user_id, NEW.body, NEW.created_at, NEW.updated_at)
+        RETURNING id INTO event_id;
+    ELSE
+        INSERT INTO chirp_events (event_type, chirp_id, user_id, body, chirp_created_at, chirp_updated_at)
+        VALUES ('chirp.deleted', OLD.id, OLD.user_id, OLD.body, OLD.created_at, OLD.updated_at)
+        RETURNING id INTO event_id;
+    END IF;
+    PERFORM pg_notify('chirp_events', event_id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+DROP TABLE spam_decisions;
+DROP TABLE spam_settings;
+DROP INDEX chirps_user_created_at_idx;
+DROP INDEX chirps_body_hash_idx;
+ALTER TABLE chirps DROP COLUMN IF EXISTS shadow_limited_at;
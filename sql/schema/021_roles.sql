@@ -0,0 +1,7 @@
+-- +goose Up
+-- moderators work the /admin/moderation queue, admins can use every /admin route
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'moderator', 'admin'));
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS role;
@@ -0,0 +1,45 @@
+-- +goose Up
+-- token buckets for RATE_LIMIT_BACKEND=postgres, shared by every instance.
+-- rows that sit idle past the longest limit period are pruned, a missing row is a full bucket
+CREATE TABLE rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX rate_limit_buckets_updated_at_idx ON rate_limit_buckets (updated_at);
+
+-- refills the bucket for the time since it was last touched, then takes a token if there is one.
+-- the row lock makes concurrent requests for the same key queue up instead of double spending
+-- +goose StatementBegin
+CREATE FUNCTION take_rate_limit_token(bucket_key TEXT, capacity DOUBLE PRECISION, refill_per_second DOUBLE PRECISION)
+RETURNS TABLE (allowed BOOLEAN, tokens DOUBLE PRECISION) AS $$
+#variable_conflict use_column
+DECLARE
+    available DOUBLE PRECISION;
+BEGIN
+    INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+    VALUES (bucket_key, capacity, NOW())
+    ON CONFLICT (key) DO NOTHING;
+
+    SELECT LEAST(capacity, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at) * refill_per_second)
+    INTO available
+    FROM rate_limit_buckets b
+    WHERE b.key = bucket_key
+    FOR UPDATE;
+
+    allowed := available >= 1;
+    IF allowed THEN
+        available := available - 1;
+    END IF;
+
+    UPDATE rate_limit_buckets SET tokens = available, updated_at = NOW() WHERE key = bucket_key;
+    tokens := available;
+    RETURN NEXT;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION IF EXISTS take_rate_limit_token(TEXT, DOUBLE PRECISION, DOUBLE PRECISION);
+DROP TABLE rate_limit_buckets;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- responses to requests sent with an Idempotency-Key, replayed when a client retries with the same key.
+-- keys are scoped to the user that sent them ('ip:<client ip>' without a token). a row is only ever
+-- committed together with its response, and is free to be claimed again once it expires
+CREATE TABLE idempotency_keys (
+    scope TEXT NOT NULL,
+    key TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    request_hash TEXT NOT NULL,
+    status_code INTEGER,
+    content_type TEXT NOT NULL DEFAULT '',
+    response_body BYTEA,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+-- +goose Down
+DROP TABLE idempotency_keys;
@@ -0,0 +1,30 @@
+-- +goose Up
+-- chirp_events.id is handed out when the row is inserted, but the transactions inserting them commit
+-- in any order, so a stream resuming after id n could skip an event that committed late with a lower id.
+-- seq is handed out after commit instead, by one sequencer at a time, and is what streams resume from
+ALTER TABLE chirp_events ADD COLUMN seq BIGINT UNIQUE;
+UPDATE chirp_events SET seq = id;
+
+-- every listener calls this when woken up. the lock makes concurrent calls take turns, and the UPDATE
+-- takes its snapshot after the lock is held, so it sees everything the previous call saw and more
+-- +goose StatementBegin
+CREATE FUNCTION sequence_chirp_events() RETURNS BIGINT AS $$
+DECLARE
+    sequenced BIGINT;
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('sequence_chirp_events'));
+    WITH pending AS (
+        SELECT id, row_number() OVER (ORDER BY id) AS n FROM chirp_events WHERE seq IS NULL
+    )
+    UPDATE chirp_events SET seq = (SELECT COALESCE(MAX(seq), 0) FROM chirp_events) + pending.n
+    FROM pending
+    WHERE chirp_events.id = pending.id;
+    GET DIAGNOSTICS sequenced = ROW_COUNT;
+    RETURN sequenced;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION sequence_chirp_events();
+ALTER TABLE chirp_events DROP COLUMN seq;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- the Content-Language a chirp was scheduled with, so the worker filters it with that locale's rules
+ALTER TABLE scheduled_chirps ADD COLUMN locale TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE scheduled_chirps DROP COLUMN locale;
@@ -0,0 +1,61 @@
+-- +goose Up
+-- a moderator hiding a chirp takes it out of every feed, so streams get the same chirp.deleted
+-- a real delete sends. chirps that were never announced (shadow limited) or are already hidden stay quiet
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_chirp_event() RETURNS trigger AS $$
+DECLARE
+    event_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' OR TG_NAME = 'chirps_record_hide' THEN
+        IF OLD.shadow_limited_at IS NOT NULL OR OLD.hidden_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO chirp_events (event_type, chirp_id, user_id, body, chirp_created_at, chirp_updated_at)
+        VALUES ('chirp.deleted', OLD.id, OLD.user_id, OLD.body, OLD.created_at, OLD.updated_at)
+        RETURNING id INTO event_id;
+    ELSE
+        IF NEW.shadow_limited_at IS NOT NULL OR NEW.hidden_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO chirp_events (event_type, chirp_id, user_id, body, chirp_created_at, chirp_updated_at)
+        VALUES ('chirp.created', NEW.id, NEW.user_id, NEW.body, NEW.created_at, NEW.updated_at)
+        RETURNING id INTO event_id;
+    END IF;
+    PERFORM pg_notify('chirp_events', event_id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_record_hide
+AFTER UPDATE OF hidden_at ON chirps
+FOR EACH ROW WHEN (OLD.hidden_at IS NULL AND NEW.hidden_at IS NOT NULL)
+EXECUTE FUNCTION record_chirp_event();
+
+-- +goose Down
+DROP TRIGGER chirps_record_hide ON chirps;
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_chirp_event() RETURNS trigger AS $$
+DECLARE
+    event_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF OLD.shadow_limited_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO chirp_events (event_type, chirp_id, user_id, body, chirp_created_at, chirp_updated_at)
+        VALUES ('chirp.deleted', OLD.id, OLD.user_id, OLD.body, OLD.created_at, OLD.updated_at)
+        RETURNING id INTO event_id;
+    ELSE
+        IF NEW.shadow_limited_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO chirp_events (event_type, chirp_id, user_id, body, chirp_created_at, chirp_updated_at)
+        VALUES ('chirp.created', NEW.id, NEW.user_id, NEW.body, NEW.created_at, NEW.updated_at)
+        RETURNING id INTO event_id;
+    END IF;
+    PERFORM pg_notify('chirp_events', event_id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
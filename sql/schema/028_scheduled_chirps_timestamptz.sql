@@ -0,0 +1,8 @@
+-- +goose Up
+-- publish_at is written from Go in UTC but compared with NOW(), which reads a plain TIMESTAMP in the
+-- session's time zone. with a time zone on the column both sides name the same instant.
+-- existing rows were written in UTC
+ALTER TABLE scheduled_chirps ALTER COLUMN publish_at TYPE TIMESTAMPTZ USING publish_at AT TIME ZONE 'UTC';
+
+-- +goose Down
+ALTER TABLE scheduled_chirps ALTER COLUMN publish_at TYPE TIMESTAMP USING publish_at AT TIME ZONE 'UTC';
@@ -0,0 +1,7 @@
+-- +goose Up
+-- current_period_end comes from Polka events in UTC and expiry compares it with NOW(), so it needs
+-- a time zone like publish_at. legacy rows from 010 were written by NOW() on a UTC server
+ALTER TABLE subscriptions ALTER COLUMN current_period_end TYPE TIMESTAMPTZ USING current_period_end AT TIME ZONE 'UTC';
+
+-- +goose Down
+ALTER TABLE subscriptions ALTER COLUMN current_period_end TYPE TIMESTAMP USING current_period_end AT TIME ZONE 'UTC';
@@ -0,0 +1,7 @@
+-- +goose Up
+-- next_attempt_at is set by NOW() when queued and from Go in UTC after a failed attempt, and the
+-- dispatcher compares it with NOW(). a time zone on the column keeps retries from being due hours early or late
+ALTER TABLE webhook_deliveries ALTER COLUMN next_attempt_at TYPE TIMESTAMPTZ USING next_attempt_at AT TIME ZONE 'UTC';
+
+-- +goose Down
+ALTER TABLE webhook_deliveries ALTER COLUMN next_attempt_at TYPE TIMESTAMP USING next_attempt_at AT TIME ZONE 'UTC';
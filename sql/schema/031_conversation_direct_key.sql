@@ -0,0 +1,25 @@
+-- +goose Up
+-- 1:1 conversations carry their two user ids, sorted and joined with ':', so finding one is an index
+-- lookup and two users starting a conversation at once land on the same row. group conversations leave it NULL
+ALTER TABLE conversations ADD COLUMN direct_key TEXT;
+
+-- if a pair already ended up with more than one conversation the oldest keeps the key
+UPDATE conversations SET direct_key = pairs.direct_key
+FROM (
+    SELECT DISTINCT ON (keyed.direct_key) keyed.conversation_id, keyed.direct_key
+    FROM (
+        SELECT conversation_id, MIN(user_id::text) || ':' || MAX(user_id::text) AS direct_key
+        FROM conversation_participants
+        GROUP BY conversation_id
+        HAVING COUNT(*) = 2
+    ) AS keyed
+    JOIN conversations ON conversations.id = keyed.conversation_id
+    ORDER BY keyed.direct_key, conversations.created_at
+) AS pairs
+WHERE conversations.id = pairs.conversation_id;
+
+CREATE UNIQUE INDEX conversations_direct_key_idx ON conversations (direct_key);
+
+-- +goose Down
+DROP INDEX conversations_direct_key_idx;
+ALTER TABLE conversations DROP COLUMN direct_key;
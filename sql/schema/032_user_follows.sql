@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE user_follows (
+    follower_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    followed_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (follower_id, followed_id)
+);
+
+CREATE INDEX user_follows_followed_id_idx ON user_follows (followed_id);
+
+-- +goose Down
+DROP TABLE user_follows;
@@ -0,0 +1,63 @@
+-- +goose Up
+-- a reply outlives the chirp it answered, it just stops pointing at it
+ALTER TABLE chirps ADD COLUMN reply_to_id UUID REFERENCES chirps (id) ON DELETE SET NULL;
+CREATE INDEX chirps_reply_to_id_idx ON chirps (reply_to_id, created_at);
+
+-- events carry the reply target so websocket clients can follow one chirp's replies
+ALTER TABLE chirp_events ADD COLUMN reply_to_id UUID;
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_chirp_event() RETURNS trigger AS $$
+DECLARE
+    event_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' OR TG_NAME = 'chirps_record_hide' THEN
+        IF OLD.shadow_limited_at IS NOT NULL OR OLD.hidden_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO chirp_events (event_type, chirp_id, user_id, body, chirp_created_at, chirp_updated_at, reply_to_id)
+        VALUES ('chirp.deleted', OLD.id, OLD.user_id, OLD.body, OLD.created_at, OLD.updated_at, OLD.reply_to_id)
+        RETURNING id INTO event_id;
+    ELSE
+        IF NEW.shadow_limited_at IS NOT NULL OR NEW.hidden_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO chirp_events (event_type, chirp_id, user_id, body, chirp_created_at, chirp_updated_at, reply_to_id)
+        VALUES ('chirp.created', NEW.id, NEW.user_id, NEW.body, NEW.created_at, NEW.updated_at, NEW.reply_to_id)
+        RETURNING id INTO event_id;
+    END IF;
+    PERFORM pg_notify('chirp_events', event_id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_chirp_event() RETURNS trigger AS $$
+DECLARE
+    event_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' OR TG_NAME = 'chirps_record_hide' THEN
+        IF OLD.shadow_limited_at IS NOT NULL OR OLD.hidden_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO chirp_events (event_type, chirp_id, user_id, body, chirp_created_at, chirp_updated_at)
+        VALUES ('chirp.deleted', OLD.id, OLD.user_id, OLD.body, OLD.created_at, OLD.updated_at)
+        RETURNING id INTO event_id;
+    ELSE
+        IF NEW.shadow_limited_at IS NOT NULL OR NEW.hidden_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO chirp_events (event_type, chirp_id, user_id, body, chirp_created_at, chirp_updated_at)
+        VALUES ('chirp.created', NEW.id, NEW.user_id, NEW.body, NEW.created_at, NEW.updated_at)
+        RETURNING id INTO event_id;
+    END IF;
+    PERFORM pg_notify('chirp_events', event_id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+ALTER TABLE chirp_events DROP COLUMN reply_to_id;
+DROP INDEX chirps_reply_to_id_idx;
+ALTER TABLE chirps DROP COLUMN reply_to_id;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN handle TEXT UNIQUE;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN handle;
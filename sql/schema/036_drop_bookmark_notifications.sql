@@ -0,0 +1,5 @@
+-- +goose Up
+DELETE FROM notifications WHERE type = 'chirp.bookmarked';
+DELETE FROM notification_mutes WHERE type = 'chirp.bookmarked';
+
+-- +goose Down
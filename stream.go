@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/JettMingin/chirpy-bootdev/internal/logging"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	chirpEventsChannel    = "chirp_events"
+	notificationsChannel  = "notifications"
+	streamBufferSize      = 64
+	streamReplayLimit     = 500
+	streamHeartbeat       = 15 * time.Second
+	streamRetryMillis     = 3000
+	chirpEventsRetention  = 24 * time.Hour
+	chirpListenerPingFreq = 90 * time.Second
+)
+
+// one row of chirp_events, as sent to stream clients. ID is the row's seq, which follows commit order
+type streamEvent struct {
+	ID    int64
+	Type  string
+	Chirp Chirp
+}
+
+func streamEventFromDB(dbEvent database.ChirpEvent) streamEvent {
+	return streamEvent{
+		ID:   dbEvent.Seq.Int64,
+		Type: dbEvent.EventType,
+		Chirp: Chirp{
+			ID:        dbEvent.ChirpID,
+			CreatedAt: dbEvent.ChirpCreatedAt,
+			UpdatedAt: dbEvent.ChirpUpdatedAt,
+			Body:      dbEvent.Body,
+			UserID:    dbEvent.UserID,
+			ReplyToID: nullUUIDPtr(dbEvent.ReplyToID),
+		},
+	}
+}
+
+// background worker started from main. every instance LISTENs on its own connection, so a chirp
+// or notification written through any instance reaches the streams and sockets open on all of them
+func (cfg *apiConfig) runEventListener(ctx context.Context, dbURL string) {
+	listener := pq.NewListener(dbURL, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Error("event listener", "error", err)
+		}
+	})
+	defer listener.Close()
+	for _, channel := range []string{chirpEventsChannel, notificationsChannel, filterRulesChannel} {
+		if err := listener.Listen(channel); err != nil {
+			slog.Error("listening on channel", "channel", channel, "error", err)
+			return
+		}
+	}
+
+	pingTicker := time.NewTicker(chirpListenerPingFreq)
+	defer pingTicker.Stop()
+	pruneTicker := time.NewTicker(time.Hour)
+	defer pruneTicker.Stop()
+
+	//events from before this instance started were never meant for its subscribers
+	lastEventID, err := cfg.DB.GetLatestChirpEventSeq(ctx)
+	if err != nil {
+		slog.Error("loading the latest chirp event", "error", err)
+		lastEventID = -1
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pingTicker.C:
+			go listener.Ping()
+		case <-pruneTicker.C:
+			//the newest event survives, GET /api/chirps dates the latest deletion by it and
+			//the sequencer numbers on from it. the cutoff is taken on the database clock created_at was set by
+			if err := cfg.DB.DeleteOldChirpEvents(ctx, chirpEventsRetention.Seconds()); err != nil {
+				slog.Error("pruning chirp events", "error", err)
+			}
+		case notification := <-listener.Notify:
+			//nil means the connection was re-established, anything sent meanwhile was lost.
+			//missed notifications are still in the table for the next GET /api/notifications
+			if notification == nil {
+				lastEventID = cfg.publishChirpEventsAfter(ctx, lastEventID)
+				if err := cfg.reloadContentFilter(ctx); err != nil {
+					slog.Error("reloading content filter", "error", err)
+				}
+				continue
+			}
+			if notification.Channel == notificationsChannel {
+				cfg.publishNotification(ctx, notification.Extra)
+				continue
+			}
+			if notification.Channel == filterRulesChannel {
+				if err := cfg.reloadContentFilter(ctx); err != nil {
+					slog.Error("reloading content filter", "error", err)
+				}
+				continue
+			}
+			//the payload is the event's id, which says nothing about commit order. number whatever
+			//has committed since the last wake-up and publish it in seq order instead
+			lastEventID = cfg.publishChirpEventsAfter(ctx, lastEventID)
+		}
+	}
+}
+
+// sequences committed events and publishes every one after lastEventID, returning the new last seq.
+// -1 means the listener never learned where to start, it starts from the latest event instead
+func (cfg *apiConfig) publishChirpEventsAfter(ctx context.Context, lastEventID int64) int64 {
+	if _, err := cfg.DB.SequenceChirpEvents(ctx); err != nil {
+		slog.Error("sequencing chirp events", "error", err)
+		return lastEventID
+	}
+	if lastEventID < 0 {
+		latest, err := cfg.DB.GetLatestChirpEventSeq(ctx)
+		if err != nil {
+			slog.Error("loading the latest chirp event", "error", err)
+			return lastEventID
+		}
+		return latest
+	}
+	for {
+		dbEvents, err := cfg.DB.GetChirpEventsAfter(ctx, database.GetChirpEventsAfterParams{
+			Seq:   sql.NullInt64{Int64: lastEventID, Valid: true},
+			Limit: streamReplayLimit,
+		})
+		if err != nil {
+			slog.Error("publishing chirp events", "error", err)
+			return lastEventID
+		}
+		for _, dbEvent := range dbEvents {
+			cfg.ChirpEvents.Publish(streamEventFromDB(dbEvent))
+			lastEventID = dbEvent.Seq.Int64
+		}
+		if len(dbEvents) < streamReplayLimit {
+			return lastEventID
+		}
+	}
+}
+
+func (cfg *apiConfig) publishNotification(ctx context.Context, rawNotificationID string) {
+	notificationID, err := uuid.Parse(rawNotificationID)
+	if err != nil {
+		return
+	}
+	dbNotification, err := cfg.DB.GetNotification(ctx, notificationID)
+	if err != nil {
+		slog.Error("loading notification", "notification_id", notificationID, "error", err)
+		return
+	}
+	cfg.NotificationEvents.Publish(dbNotification.UserID, notificationFromDB(dbNotification))
+}
+
+func writeStreamEvent(res http.ResponseWriter, event streamEvent) error {
+	data, err := json.Marshal(event.Chirp)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+func (cfg *apiConfig) StreamChirps(res http.ResponseWriter, req *http.Request) {
+	var authorID uuid.NullUUID
+	if rawAuthorID := req.URL.Query().Get("author_id"); rawAuthorID != "" {
+		parsedID, err := uuid.Parse(rawAuthorID)
+		if err != nil {
+			res.Header().Set("Content-Type", "application/json")
+			ErrorResponseWriter(res, "failed to parse author_id", err, 400)
+			return
+		}
+		authorID = uuid.NullUUID{UUID: parsedID, Valid: true}
+	}
+	viewerID := cfg.getOptionalUserID(req)
+	hiddenAuthors, err := cfg.getHiddenAuthors(req, viewerID)
+	if err != nil {
+		res.Header().Set("Content-Type", "application/json")
+		ErrorResponseWriter(res, "failed to query for blocked and muted users in DB", err, 500)
+		return
+	}
+	//follows=true narrows the stream to the viewer's own chirps and the users they follow
+	var followedAuthors map[uuid.UUID]bool
+	if req.URL.Query().Get("follows") == "true" {
+		if !viewerID.Valid {
+			res.Header().Set("Content-Type", "application/json")
+			ErrorResponseWriter(res, "Bad Token, Unauthorized", errors.New("follows filter without a token"), 401)
+			return
+		}
+		followedAuthors, err = cfg.getFollowedAuthors(req, viewerID.UUID)
+		if err != nil {
+			res.Header().Set("Content-Type", "application/json")
+			ErrorResponseWriter(res, "failed to query for followed users in DB", err, 500)
+			return
+		}
+		followedAuthors[viewerID.UUID] = true
+	}
+	//browsers send this on their own when an EventSource reconnects
+	var lastEventID int64
+	if rawLastID := req.Header.Get("Last-Event-ID"); rawLastID != "" {
+		lastEventID, _ = strconv.ParseInt(rawLastID, 10, 64)
+	}
+
+	//the server's WriteTimeout would cut the stream off after 10s, lift it for this response only
+	rc := http.NewResponseController(res)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		res.Header().Set("Content-Type", "application/json")
+		ErrorResponseWriter(res, "streaming not supported", err, 500)
+		return
+	}
+
+	//subscribe before replaying so nothing committed in between is missed. the replay and the broker
+	//both go in seq order, so anything at or below the last id sent is a duplicate
+	//a client that can't keep up gets dropped by the broker and has to reconnect with Last-Event-ID
+	events := cfg.ChirpEvents.Subscribe()
+	defer cfg.ChirpEvents.Unsubscribe(events)
+	cfg.Metrics.streamConnections.WithLabelValues(streamSSE).Inc()
+	defer cfg.Metrics.streamConnections.WithLabelValues(streamSSE).Dec()
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(200)
+	fmt.Fprintf(res, "retry: %d\n\n", streamRetryMillis)
+
+	send := func(event streamEvent) error {
+		if event.ID <= lastEventID {
+			return nil
+		}
+		lastEventID = event.ID
+		if (authorID.Valid && event.Chirp.UserID != authorID.UUID) || hiddenAuthors[event.Chirp.UserID] ||
+			(followedAuthors != nil && !followedAuthors[event.Chirp.UserID]) {
+			return nil
+		}
+		return writeStreamEvent(res, event)
+	}
+
+	//send moves lastEventID forward, so each page picks up where the last one stopped
+	for lastEventID > 0 {
+		dbEvents, err := cfg.DB.GetChirpEventsAfter(req.Context(), database.GetChirpEventsAfterParams{
+			Seq:   sql.NullInt64{Int64: lastEventID, Valid: true},
+			Limit: streamReplayLimit,
+		})
+		if err != nil {
+			logging.FromContext(req.Context()).Error("replaying chirp events", "error", err)
+			return
+		}
+		for _, dbEvent := range dbEvents {
+			if err := send(streamEventFromDB(dbEvent)); err != nil {
+				return
+			}
+		}
+		if len(dbEvents) < streamReplayLimit {
+			break
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-heartbeat.C:
+			//comment lines keep proxies from closing an idle connection
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := send(event); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/auth"
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+)
+
+type WebhookSubscription struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	Secret    string    `json:"secret,omitempty"`
+}
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode *int32          `json:"last_status_code"`
+	LastError      *string         `json:"last_error"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+func webhookSubscriptionFromDB(dbSubscription database.WebhookSubscription) WebhookSubscription {
+	return WebhookSubscription{
+		ID:        dbSubscription.ID,
+		CreatedAt: dbSubscription.CreatedAt,
+		UpdatedAt: dbSubscription.UpdatedAt,
+		URL:       dbSubscription.Url,
+		Events:    dbSubscription.Events,
+		Active:    dbSubscription.Active,
+	}
+}
+
+func webhookDeliveryFromDB(dbDelivery database.WebhookDelivery) WebhookDelivery {
+	delivery := WebhookDelivery{
+		ID:            dbDelivery.ID,
+		CreatedAt:     dbDelivery.CreatedAt,
+		UpdatedAt:     dbDelivery.UpdatedAt,
+		EventType:     dbDelivery.EventType,
+		Payload:       dbDelivery.Payload,
+		Status:        dbDelivery.Status,
+		Attempts:      dbDelivery.Attempts,
+		NextAttemptAt: dbDelivery.NextAttemptAt,
+	}
+	if dbDelivery.LastStatusCode.Valid {
+		delivery.LastStatusCode = &dbDelivery.LastStatusCode.Int32
+	}
+	if dbDelivery.LastError.Valid {
+		delivery.LastError = &dbDelivery.LastError.String
+	}
+	if dbDelivery.DeliveredAt.Valid {
+		delivery.DeliveredAt = &dbDelivery.DeliveredAt.Time
+	}
+	return delivery
+}
+
+// user routes own their subscriptions, admin routes own the ones with no user (owner is NULL)
+func (cfg *apiConfig) PostWebhook(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	cfg.createWebhookSubscription(res, req, uuid.NullUUID{UUID: userID, Valid: true})
+}
+
+func (cfg *apiConfig) GetWebhooks(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	cfg.listWebhookSubscriptions(res, req, uuid.NullUUID{UUID: userID, Valid: true})
+}
+
+func (cfg *apiConfig) DeleteWebhook(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	cfg.deleteWebhookSubscription(res, req, uuid.NullUUID{UUID: userID, Valid: true})
+}
+
+func (cfg *apiConfig) GetWebhookDeliveries(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	cfg.listWebhookDeliveries(res, req, uuid.NullUUID{UUID: userID, Valid: true})
+}
+
+func (cfg *apiConfig) RedriveWebhookDelivery(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+	userID, err := cfg.getAuthedUserID(req)
+	if err != nil {
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	cfg.redriveWebhookDelivery(res, req, uuid.NullUUID{UUID: userID, Valid: true})
+}
+
+func (cfg *apiConfig) PostAdminWebhook(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+	cfg.createWebhookSubscription(res, req, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) GetAdminWebhooks(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+	cfg.listWebhookSubscriptions(res, req, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) DeleteAdminWebhook(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+	cfg.deleteWebhookSubscription(res, req, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) GetAdminWebhookDeliveries(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+	cfg.listWebhookDeliveries(res, req, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) RedriveAdminWebhookDelivery(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+	cfg.redriveWebhookDelivery(res, req, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) createWebhookSubscription(res http.ResponseWriter, req *http.Request, owner uuid.NullUUID) {
+	type WebhookRequest struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	reqData, err := io.ReadAll(req.Body)
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to read request body", err, 500)
+		return
+	}
+	var webhookReq WebhookRequest
+	if err := json.Unmarshal(reqData, &webhookReq); err != nil {
+		ErrorResponseWriter(res, "Failed to decode request body", err, 400)
+		return
+	}
+
+	//plain http is only allowed for local testing
+	parsedURL, err := url.Parse(webhookReq.URL)
+	if err != nil || parsedURL.Host == "" ||
+		(parsedURL.Scheme != "https" && !(parsedURL.Scheme == "http" && cfg.Platform == "dev")) {
+		err := errors.New("invalid webhook url")
+		ErrorResponseWriter(res, "url must be an absolute https url", err, 400)
+		return
+	}
+	//hostnames are checked again on every delivery, after they resolve
+	if cfg.Platform != "dev" {
+		addr, err := netip.ParseAddr(parsedURL.Hostname())
+		if (err == nil && !webhookAddrAllowed(addr)) || strings.EqualFold(parsedURL.Hostname(), "localhost") {
+			err := errors.New("webhook url points at a non-public address")
+			ErrorResponseWriter(res, "url must point at a public address", err, 400)
+			return
+		}
+	}
+	if len(webhookReq.Events) == 0 {
+		err := errors.New("missing events field")
+		ErrorResponseWriter(res, "events must list at least one event type", err, 400)
+		return
+	}
+	for _, event := range webhookReq.Events {
+		if !slices.Contains(webhookEventTypes, event) {
+			err := errors.New("unknown event type: " + event)
+			ErrorResponseWriter(res, "events contains an unsupported event type", err, 400)
+			return
+		}
+		if owner.Valid && slices.Contains(adminOnlyWebhookEvents, event) {
+			err := errors.New("admin only event type: " + event)
+			ErrorResponseWriter(res, "events contains an event type only admins can subscribe to", err, 403)
+			return
+		}
+	}
+	slices.Sort(webhookReq.Events)
+	webhookReq.Events = slices.Compact(webhookReq.Events)
+
+	dbSubscription, err := cfg.DB.CreateWebhookSubscription(req.Context(), database.CreateWebhookSubscriptionParams{
+		UserID: owner,
+		Url:    parsedURL.String(),
+		Secret: "whsec_" + auth.MakeRefreshToken(),
+		Events: webhookReq.Events,
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to write webhook subscription to DB", err, 500)
+		return
+	}
+
+	//the secret is only ever shown once, on create
+	newSubscription := webhookSubscriptionFromDB(dbSubscription)
+	newSubscription.Secret = dbSubscription.Secret
+	successRes, err := json.Marshal(newSubscription)
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(201)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) listWebhookSubscriptions(res http.ResponseWriter, req *http.Request, owner uuid.NullUUID) {
+	var dbSubscriptions []database.WebhookSubscription
+	var err error
+	if owner.Valid {
+		dbSubscriptions, err = cfg.DB.GetUsersWebhookSubscriptions(req.Context(), owner)
+	} else {
+		dbSubscriptions, err = cfg.DB.GetAdminWebhookSubscriptions(req.Context())
+	}
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for webhook subscriptions in DB", err, 500)
+		return
+	}
+
+	selectedSubscriptions := []WebhookSubscription{}
+	for _, row := range dbSubscriptions {
+		selectedSubscriptions = append(selectedSubscriptions, webhookSubscriptionFromDB(row))
+	}
+
+	successRes, err := json.Marshal(selectedSubscriptions)
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+func (cfg *apiConfig) deleteWebhookSubscription(res http.ResponseWriter, req *http.Request, owner uuid.NullUUID) {
+	dbSubscription, statusCode, err := cfg.getOwnedWebhookSubscription(req, owner)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to find webhook subscription with provided id", err, statusCode)
+		return
+	}
+	if err := cfg.DB.DeleteWebhookSubscription(req.Context(), dbSubscription.ID); err != nil {
+		ErrorResponseWriter(res, "Failed to delete webhook subscription in DB", err, 500)
+		return
+	}
+	res.WriteHeader(204)
+}
+
+func (cfg *apiConfig) listWebhookDeliveries(res http.ResponseWriter, req *http.Request, owner uuid.NullUUID) {
+	dbSubscription, statusCode, err := cfg.getOwnedWebhookSubscription(req, owner)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to find webhook subscription with provided id", err, statusCode)
+		return
+	}
+	limit, offset, err := getPaginationParams(req)
+	if err != nil {
+		ErrorResponseWriter(res, "invalid pagination params", err, 400)
+		return
+	}
+
+	dbDeliveries, err := cfg.DB.GetWebhookDeliveries(req.Context(), database.GetWebhookDeliveriesParams{
+		SubscriptionID: dbSubscription.ID,
+		Limit:          limit,
+		Offset:         offset,
+	})
+	if err != nil {
+		ErrorResponseWriter(res, "failed to query for webhook deliveries in DB", err, 500)
+		return
+	}
+
+	selectedDeliveries := []WebhookDelivery{}
+	for _, row := range dbDeliveries {
+		selectedDeliveries = append(selectedDeliveries, webhookDeliveryFromDB(row))
+	}
+
+	successRes, err := json.Marshal(selectedDeliveries)
+	if err != nil {
+		ErrorResponseWriter(res, "JSON", err, 500)
+		return
+	}
+	res.WriteHeader(200)
+	res.Write(successRes)
+}
+
+// puts a dead-lettered delivery back in the queue with a fresh set of attempts
+func (cfg *apiConfig) redriveWebhookDelivery(res http.ResponseWriter, req *http.Request, owner uuid.NullUUID) {
+	dbSubscription, statusCode, err := cfg.getOwnedWebhookSubscription(req, owner)
+	if err != nil {
+		ErrorResponseWriter(res, "failed to find webhook subscription with provided id", err, statusCode)
+		return
+	}
+	deliveryID, err := uuid.Parse(req.PathValue("deliveryId"))
+	if err != nil {
+		ErrorResponseWriter(res, "failed to parse uuid provided in url", err, 400)
+		return
+	}
+
+	redriven, err := cfg.DB.RedriveWebhookDelivery(req.Context(),
+		database.RedriveWebhookDeliveryParams{ID: deliveryID, SubscriptionID: dbSubscription.ID})
+	if err != nil {
+		ErrorResponseWriter(res, "Failed to update webhook delivery in DB", err, 500)
+		return
+	}
+	if redriven == 0 {
+		err := errors.New("dead delivery not found")
+		ErrorResponseWriter(res, "failed to find a dead-lettered delivery with provided id", err, 404)
+		return
+	}
+	res.WriteHeader(204)
+}
+
+// other owners' subscriptions come back as 404 so ids can't be probed
+func (cfg *apiConfig) getOwnedWebhookSubscription(req *http.Request, owner uuid.NullUUID) (database.WebhookSubscription, int, error) {
+	subscriptionID, err := uuid.Parse(req.PathValue("webhookId"))
+	if err != nil {
+		return database.WebhookSubscription{}, 400, err
+	}
+	dbSubscription, err := cfg.DB.GetWebhookSubscription(req.Context(), subscriptionID)
+	if err != nil {
+		return database.WebhookSubscription{}, 404, err
+	}
+	if dbSubscription.UserID != owner {
+		return database.WebhookSubscription{}, 404, errors.New("webhook subscription not found")
+	}
+	return dbSubscription, 0, nil
+}
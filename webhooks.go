@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/auth"
+	"github.com/JettMingin/chirpy-bootdev/internal/database"
+	"github.com/google/uuid"
+)
+
+// event types third parties can subscribe to
+const (
+	webhookChirpCreated   = "chirp.created"
+	webhookChirpDeleted   = "chirp.deleted"
+	webhookUserUpgraded   = "user.upgraded"
+	webhookUserDowngraded = "user.downgraded"
+	webhookFollowCreated  = "follow.created"
+)
+
+var webhookEventTypes = []string{webhookChirpCreated, webhookChirpDeleted, webhookUserUpgraded, webhookUserDowngraded, webhookFollowCreated}
+
+// events about one user's account. only admin subscriptions receive them, users can't subscribe to them
+// and a user subscription that lists one from before that rule is skipped when fanning out
+var adminOnlyWebhookEvents = []string{webhookUserUpgraded, webhookUserDowngraded}
+
+// follow.created data, sent to admin subscriptions and to the followed user's own
+type webhookFollow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FollowedID uuid.UUID `json:"followed_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+const (
+	webhookBatchSize   = 20
+	webhookMaxAttempts = 10
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+	webhookTimeout     = 10 * time.Second
+
+	webhookEventHeader     = "Chirpy-Event"
+	webhookDeliveryHeader  = "Chirpy-Delivery"
+	webhookTimestampHeader = "Chirpy-Timestamp"
+	webhookSignatureHeader = "Chirpy-Signature"
+)
+
+// the JSON body every subscriber receives. id is the same for every subscription the event fans out to
+type webhookEvent struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// writes one pending delivery per matching subscription into the outbox. always pass the qtx of the
+// transaction that made the change, so the event only exists if that transaction commits
+func enqueueWebhookEvent(ctx context.Context, qtx *database.Queries, eventType string, data any) error {
+	return enqueueWebhookEventFor(ctx, qtx, eventType, data, uuid.NullUUID{}, slices.Contains(adminOnlyWebhookEvents, eventType))
+}
+
+// like enqueueWebhookEvent, for events only admins and userID's own subscriptions should hear about
+func enqueuePersonalWebhookEvent(ctx context.Context, qtx *database.Queries, eventType string, data any, userID uuid.UUID) error {
+	return enqueueWebhookEventFor(ctx, qtx, eventType, data, uuid.NullUUID{UUID: userID, Valid: true}, true)
+}
+
+func enqueueWebhookEventFor(ctx context.Context, qtx *database.Queries, eventType string, data any, audienceID uuid.NullUUID, adminOnly bool) error {
+	payload, err := json.Marshal(webhookEvent{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = qtx.EnqueueWebhookEvent(ctx, database.EnqueueWebhookEventParams{
+		EventType:  eventType,
+		Payload:    payload,
+		AdminOnly:  adminOnly,
+		AudienceID: audienceID,
+	})
+	return err
+}
+
+// subscriber urls are user input, so the dispatcher must not be usable to reach our own network.
+// only ranges in this list and the ones netip classifies as non-public are refused
+var webhookBlockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+func webhookAddrAllowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return false
+	}
+	for _, prefix := range webhookBlockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// the check runs in the dialer after DNS resolution, so a hostname pointing at 127.0.0.1 is caught too.
+// redirects aren't followed (a 3xx is a failed delivery) and proxies from the environment aren't used,
+// either would connect somewhere the check never saw. dev allows private addresses for local testing
+func newWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !allowPrivate && !webhookAddrAllowed(addrPort.Addr()) {
+				return fmt.Errorf("webhook url resolves to a non-public address %s", addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// 30s, 1m, 2m, 4m ... capped at 6h
+func webhookBackoff(attempts int32) time.Duration {
+	backoff := webhookBaseBackoff
+	for i := int32(1); i < attempts && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, webhookMaxBackoff)
+}
+
+// background worker started from main. claiming pushes next_attempt_at forward, which works as a lease,
+// so other instances skip these rows while the HTTP calls happen outside any transaction
+func (cfg *apiConfig) runWebhookDispatcher(ctx context.Context, interval time.Duration) {
+	client := newWebhookClient(cfg.Platform == "dev")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				claimed, err := cfg.DB.ClaimDueWebhookDeliveries(ctx, webhookBatchSize)
+				if err != nil {
+					slog.Error("claiming webhook deliveries", "error", err)
+					break
+				}
+				subscriptions := map[uuid.UUID]database.WebhookSubscription{}
+				for _, delivery := range claimed {
+					cfg.attemptWebhookDelivery(ctx, client, delivery, subscriptions)
+				}
+				if len(claimed) < webhookBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+func (cfg *apiConfig) attemptWebhookDelivery(ctx context.Context, client *http.Client, delivery database.WebhookDelivery, subscriptions map[uuid.UUID]database.WebhookSubscription) {
+	subscription, ok := subscriptions[delivery.SubscriptionID]
+	if !ok {
+		dbSubscription, err := cfg.DB.GetWebhookSubscription(ctx, delivery.SubscriptionID)
+		if err != nil {
+			slog.Error("loading webhook subscription", "subscription_id", delivery.SubscriptionID, "error", err)
+			return
+		}
+		subscription = dbSubscription
+		subscriptions[subscription.ID] = subscription
+	}
+
+	statusCode, deliveryErr := sendWebhook(ctx, client, subscription, delivery)
+	lastStatusCode := sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0}
+	if deliveryErr == nil {
+		if err := cfg.DB.MarkWebhookDelivered(ctx, database.MarkWebhookDeliveredParams{
+			LastStatusCode: lastStatusCode,
+			ID:             delivery.ID,
+		}); err != nil {
+			slog.Error("marking webhook delivery delivered", "delivery_id", delivery.ID, "error", err)
+		}
+		return
+	}
+
+	attempts := delivery.Attempts + 1
+	status := "pending"
+	if attempts >= webhookMaxAttempts || !subscription.Active {
+		status = "dead"
+	}
+	if err := cfg.DB.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+		Status:         status,
+		NextAttemptAt:  time.Now().UTC().Add(webhookBackoff(attempts)),
+		LastStatusCode: lastStatusCode,
+		LastError:      sql.NullString{String: deliveryErr.Error(), Valid: true},
+		ID:             delivery.ID,
+	}); err != nil {
+		slog.Error("marking webhook delivery failed", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// POSTs the payload signed with the subscription's secret. anything but a 2xx counts as a failure
+func sendWebhook(ctx context.Context, client *http.Client, subscription database.WebhookSubscription, delivery database.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", subscription.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, delivery.EventType)
+	req.Header.Set(webhookDeliveryHeader, delivery.ID.String())
+	req.Header.Set(webhookTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(webhookSignatureHeader, auth.SignWebhookPayload(subscription.Secret, now, delivery.Payload))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("subscriber responded with %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JettMingin/chirpy-bootdev/internal/auth"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsMaxConnsPerUser = 5
+	wsMaxMessageSize  = 4096
+	wsWriteWait       = 10 * time.Second
+	wsPongWait        = 60 * time.Second
+	wsPingPeriod      = wsPongWait * 9 / 10
+
+	//every chirp, the chirps of one user ("user:<id>"), the replies to one chirp ("replies:<id>"),
+	//and your own notifications
+	wsChannelChirps        = "chirps"
+	wsChannelUserPrefix    = "user:"
+	wsChannelRepliesPrefix = "replies:"
+	wsChannelNotifications = "notifications"
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// what clients send: {"action":"subscribe","channel":"chirps"}
+type wsClientMessage struct {
+	Action  string `json:"action"`
+	Channel string `json:"channel"`
+}
+
+// everything the server sends has a type, events also say which subscription they came from
+type wsServerMessage struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel,omitempty"`
+	Data    any    `json:"data,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// open sockets per user, across this instance only
+type wsConnLimiter struct {
+	mu     sync.Mutex
+	counts map[uuid.UUID]int
+}
+
+func newWSConnLimiter() *wsConnLimiter {
+	return &wsConnLimiter{counts: map[uuid.UUID]int{}}
+}
+
+func (l *wsConnLimiter) acquire(userID uuid.UUID) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counts[userID] >= wsMaxConnsPerUser {
+		return false
+	}
+	l.counts[userID]++
+	return true
+}
+
+func (l *wsConnLimiter) release(userID uuid.UUID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counts[userID]--
+	if l.counts[userID] <= 0 {
+		delete(l.counts, userID)
+	}
+}
+
+func validateWSChannel(channel string) error {
+	if channel == wsChannelChirps || channel == wsChannelNotifications {
+		return nil
+	}
+	if rawUserID, ok := strings.CutPrefix(channel, wsChannelUserPrefix); ok {
+		_, err := uuid.Parse(rawUserID)
+		return err
+	}
+	if rawChirpID, ok := strings.CutPrefix(channel, wsChannelRepliesPrefix); ok {
+		_, err := uuid.Parse(rawChirpID)
+		return err
+	}
+	return errors.New("unknown channel")
+}
+
+func wsChannelMatches(channel string, event streamEvent) bool {
+	if channel == wsChannelChirps {
+		return true
+	}
+	if event.Chirp.ReplyToID != nil && channel == wsChannelRepliesPrefix+event.Chirp.ReplyToID.String() {
+		return true
+	}
+	return channel == wsChannelUserPrefix+event.Chirp.UserID.String()
+}
+
+// browsers can't set headers on a websocket handshake, so the JWT may also come as ?token=
+func (cfg *apiConfig) getWSUserID(req *http.Request) (uuid.UUID, error) {
+	if tokenString := req.URL.Query().Get("token"); tokenString != "" {
+		return auth.ValidateJWT(tokenString, cfg.TokenSecret)
+	}
+	return cfg.getAuthedUserID(req)
+}
+
+func (cfg *apiConfig) ServeWebSocket(res http.ResponseWriter, req *http.Request) {
+	userID, err := cfg.getWSUserID(req)
+	if err != nil {
+		res.Header().Set("Content-Type", "application/json")
+		ErrorResponseWriter(res, "Bad Token, Unauthorized", err, 401)
+		return
+	}
+	if !cfg.WSConns.acquire(userID) {
+		res.Header().Set("Content-Type", "application/json")
+		ErrorResponseWriter(res, "Too many open websocket connections", errors.New("connection limit reached"), 429)
+		return
+	}
+	defer cfg.WSConns.release(userID)
+	hiddenAuthors, err := cfg.getHiddenAuthors(req, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		res.Header().Set("Content-Type", "application/json")
+		ErrorResponseWriter(res, "failed to query for blocked and muted users in DB", err, 500)
+		return
+	}
+
+	//Upgrade writes its own error response
+	conn, err := wsUpgrader.Upgrade(res, req, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	cfg.Metrics.streamConnections.WithLabelValues(streamWebSocket).Inc()
+	defer cfg.Metrics.streamConnections.WithLabelValues(streamWebSocket).Dec()
+
+	client := &wsClient{
+		conn:          conn,
+		hiddenAuthors: hiddenAuthors,
+		chirps:        cfg.ChirpEvents.Subscribe(),
+		notifications: cfg.NotificationEvents.Subscribe(userID),
+		control:       make(chan wsClientMessage, 16),
+		done:          make(chan struct{}),
+	}
+	defer cfg.ChirpEvents.Unsubscribe(client.chirps)
+	defer cfg.NotificationEvents.Unsubscribe(userID, client.notifications)
+	go client.writeLoop()
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	//stop the writer before the deferred Unsubscribes close its event channels
+	defer func() {
+		close(client.control)
+		<-client.done
+	}()
+	for {
+		var msg wsClientMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		select {
+		case client.control <- msg:
+		case <-client.done:
+			return
+		}
+	}
+}
+
+// one open socket. writeLoop is the only goroutine that writes to conn and it owns the
+// subscriptions, so the reader hands subscribe/unsubscribe messages over instead of touching them
+type wsClient struct {
+	conn          *websocket.Conn
+	hiddenAuthors map[uuid.UUID]bool
+	chirps        chan streamEvent
+	notifications chan Notification
+	control       chan wsClientMessage
+	done          chan struct{}
+	subscriptions map[string]struct{}
+}
+
+func (c *wsClient) write(msg wsServerMessage) error {
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return c.conn.WriteJSON(msg)
+}
+
+// the broker closed one of our channels for falling behind
+func (c *wsClient) closeTooSlow() {
+	c.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow"),
+		time.Now().Add(wsWriteWait))
+}
+
+func (c *wsClient) handleControl(msg wsClientMessage) error {
+	reply := wsServerMessage{Channel: msg.Channel}
+	if err := validateWSChannel(msg.Channel); err != nil {
+		reply.Type = "error"
+		reply.Error = err.Error()
+	} else if msg.Action == "subscribe" {
+		c.subscriptions[msg.Channel] = struct{}{}
+		reply.Type = "subscribed"
+	} else if msg.Action == "unsubscribe" {
+		delete(c.subscriptions, msg.Channel)
+		reply.Type = "unsubscribed"
+	} else {
+		reply.Type = "error"
+		reply.Error = "action must be subscribe or unsubscribe"
+	}
+	return c.write(reply)
+}
+
+func (c *wsClient) writeLoop() {
+	defer close(c.done)
+	defer c.conn.Close()
+
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+	c.subscriptions = map[string]struct{}{}
+
+	for {
+		select {
+		case msg, ok := <-c.control:
+			if !ok {
+				return
+			}
+			if err := c.handleControl(msg); err != nil {
+				return
+			}
+
+		case event, ok := <-c.chirps:
+			if !ok {
+				c.closeTooSlow()
+				return
+			}
+			if c.hiddenAuthors[event.Chirp.UserID] {
+				continue
+			}
+			for channel := range c.subscriptions {
+				if !wsChannelMatches(channel, event) {
+					continue
+				}
+				if err := c.write(wsServerMessage{Type: event.Type, Channel: channel, Data: event.Chirp}); err != nil {
+					return
+				}
+			}
+
+		case notification, ok := <-c.notifications:
+			if !ok {
+				c.closeTooSlow()
+				return
+			}
+			//only this user's notifications come through, they're dropped unless the client asked for them
+			if _, subscribed := c.subscriptions[wsChannelNotifications]; !subscribed {
+				continue
+			}
+			if err := c.write(wsServerMessage{Type: "notification", Channel: wsChannelNotifications, Data: notification}); err != nil {
+				return
+			}
+
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}